@@ -0,0 +1,139 @@
+// Package apperr はユースケースが返すエラー
+//
+// エラーごとに種類 (Kind) と機械向けのコード ("item_not_found" など) を持たせておき、
+// HTTP のステータスとレスポンスの本文はコントローラーの writeError が1か所で決める
+// (ユースケースは HTTP を知らなくてよい)
+//
+// 各パッケージはセンチネルとして定義し、状況に応じて With で詳細を、Wrap で元のエラーを足して返す:
+//
+//	var ErrInvalidItem = apperr.New(apperr.Validation, "invalid_item", "invalid item")
+//	return ErrInvalidItem.With("field", "price")
+//
+// errors.Is(err, ErrInvalidItem) はコードが同じなら true になる
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kind: エラーの種類 (HTTP のステータスに対応する)
+type Kind int
+
+const (
+	Internal     Kind = iota // 想定外 (DB など)。apperr でないエラーもこれとして扱う
+	NotFound                 // 対象がない
+	Validation               // 入力がおかしい
+	Conflict                 // 今の状態ではできない (売り切れ・登録済みなど)
+	Unauthorized             // ログインしていない・トークンが無効
+	Forbidden                // ログインしているが権限がない
+	TooLarge                 // 入力が大きすぎる (画像など)
+	Unsupported              // 対応していない形式 (画像など)
+	Upstream                 // 外部サービス (Gemini など) の失敗
+)
+
+var kindNames = map[Kind]string{
+	Internal:     "internal",
+	NotFound:     "not_found",
+	Validation:   "validation",
+	Conflict:     "conflict",
+	Unauthorized: "unauthorized",
+	Forbidden:    "forbidden",
+	TooLarge:     "too_large",
+	Unsupported:  "unsupported",
+	Upstream:     "upstream",
+}
+
+func (k Kind) String() string {
+	if s, ok := kindNames[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Error: 種類・コード・英語の説明と、あれば詳細と元のエラー
+type Error struct {
+	Kind    Kind
+	Code    string         // レスポンスの code (フロントはこれで分岐する)
+	Message string         // 英語の説明 (ログ用。ユーザー向けの文言はコントローラーが code から引く)
+	Details map[string]any // 何がだめだったか ("field" など)。レスポンスの details にそのまま出す
+	Err     error          // 元のエラー (ログにだけ出し、レスポンスには出さない)
+}
+
+// New: センチネルを作る
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Error: "invalid item (field=price max=9999999): 元のエラー"
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	if len(e.Details) > 0 {
+		keys := make([]string, 0, len(e.Details))
+		for k := range e.Details {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString(" (")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "%s=%v", k, e.Details[k])
+		}
+		b.WriteString(")")
+	}
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+// Is: コードが同じなら同じエラー (With・Wrap で作ったコピーもセンチネルと一致する)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With: 詳細を1つ足したコピー (センチネル自体は変えない)
+func (e *Error) With(key string, value any) *Error {
+	c := *e
+	c.Details = make(map[string]any, len(e.Details)+1)
+	for k, v := range e.Details {
+		c.Details[k] = v
+	}
+	c.Details[key] = value
+	return &c
+}
+
+// Wrap: 元のエラーを持たせたコピー
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// From: err の中の *Error (なければ nil)
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
+
+// KindOf: err の種類 (apperr でなければ Internal)
+func KindOf(err error) Kind {
+	if e := From(err); e != nil {
+		return e.Kind
+	}
+	return Internal
+}
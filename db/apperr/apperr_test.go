@@ -0,0 +1,48 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errThing = New(NotFound, "thing_not_found", "thing not found")
+
+func TestWithKeepsIdentity(t *testing.T) {
+	err := fmt.Errorf("load: %w", errThing.With("id", 3))
+	if !errors.Is(err, errThing) {
+		t.Error("With したコピーもセンチネルと一致するはず")
+	}
+	if e := From(err); e == nil || e.Details["id"] != 3 {
+		t.Errorf("From = %+v", e)
+	}
+	if errThing.Details != nil {
+		t.Errorf("センチネルが書き換わった: %v", errThing.Details)
+	}
+	if got := err.Error(); got != "load: thing not found (id=3)" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestWrapKeepsCause(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := errThing.Wrap(cause)
+	if !errors.Is(err, errThing) || !errors.Is(err, cause) {
+		t.Error("センチネルと元のエラーの両方と一致するはず")
+	}
+	if got := err.Error(); got != "thing not found: connection refused" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	if KindOf(errThing) != NotFound {
+		t.Error("NotFound のはず")
+	}
+	if KindOf(errors.New("sql: connection is already closed")) != Internal {
+		t.Error("apperr でなければ Internal のはず")
+	}
+	if New(Conflict, "x", "x").Is(New(Conflict, "y", "y")) {
+		t.Error("コードが違えば別のエラー")
+	}
+}
@@ -0,0 +1,27 @@
+package auth
+
+import "context"
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	sessionIDKey
+)
+
+// WithUser: 認証済みのユーザーIDとセッションIDを context に入れる
+func WithUser(ctx context.Context, userID int, sessionID string) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, userID)
+	return context.WithValue(ctx, sessionIDKey, sessionID)
+}
+
+// UserIDFromContext: 認証済みなら呼び出し元のユーザーIDを返す
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(userIDKey).(int)
+	return id, ok && id != 0
+}
+
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(sessionIDKey).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"db/apperr"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// JWKS の Cache-Control が無いときのキャッシュ期間
+	defaultJWKSCacheTTL = time.Hour
+	// 知らない kid が来たときに JWKS を取り直す最短間隔 (連打で外部APIを叩かないように)
+	minJWKSRefreshInterval = time.Minute
+)
+
+// Google が発行する ID トークンの iss
+var GoogleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// ErrJWKSUnavailable: 公開鍵 (JWKS) を取れなかった。トークンが悪いわけではないので 401 にはしない
+var ErrJWKSUnavailable = apperr.New(apperr.Upstream, "jwks_unavailable", "failed to fetch signing keys")
+
+// IDTokenClaims: ID トークンのうち、ログインに使う項目
+type IDTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenVerifier: OIDC の ID トークンを JWKS の公開鍵で検証する
+// 公開鍵はキャッシュし、期限切れや未知の kid (鍵のローテーション) のときに取り直す
+type IDTokenVerifier struct {
+	JWKSURL  string
+	Issuers  []string
+	Audience string // OAuth クライアントID
+	Client   *http.Client
+
+	fetch       singleflight.Group // 同時に取り直しが要ったら、1回の取得を待ち合わせる
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	expiresAt   time.Time
+	lastFetched time.Time
+	now         func() time.Time
+}
+
+func NewIDTokenVerifier(jwksURL string, issuers []string, audience string) *IDTokenVerifier {
+	return &IDTokenVerifier{
+		JWKSURL:  jwksURL,
+		Issuers:  issuers,
+		Audience: audience,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// Verify: 署名・iss・aud・exp を検証してクレームを返す
+func (v *IDTokenVerifier) Verify(ctx context.Context, idToken string) (*IDTokenClaims, error) {
+	if v.Audience == "" {
+		return nil, fmt.Errorf("id token verifier: audience is not configured")
+	}
+
+	var claims IDTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithAudience(v.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+		jwt.WithTimeFunc(v.now),
+	)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrTokenExpired
+	}
+	if errors.Is(err, ErrJWKSUnavailable) {
+		return nil, apperr.From(err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenInvalid, err)
+	}
+
+	if !slices.Contains(v.Issuers, claims.Issuer) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrTokenInvalid, claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrTokenInvalid)
+	}
+	return &claims, nil
+}
+
+// key: kid に対応する公開鍵を返す。必要なら JWKS を取り直す
+// 取り直しは外部 API を待つので、ロックを持ったままにはしない
+func (v *IDTokenVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	now := v.now()
+	key, ok := v.keys[kid]
+	fresh := now.Before(v.expiresAt)
+	recent := now.Sub(v.lastFetched) < minJWKSRefreshInterval
+	v.mu.Unlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+	// キャッシュが有効なのに kid が無い場合は、直近で取り直していなければ取り直す
+	if fresh && recent {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	// 最初に来たリクエストが切断されても、待ち合わせている他のリクエストまで失敗しないようにする
+	// (取得自体は Client のタイムアウトで止まる)
+	if _, err, _ := v.fetch.Do("jwks", func() (any, error) {
+		return nil, v.refresh(context.WithoutCancel(ctx))
+	}); err != nil {
+		return nil, ErrJWKSUnavailable.Wrap(err)
+	}
+
+	v.mu.Lock()
+	key, ok = v.keys[kid]
+	v.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// refresh: JWKS を取得してキャッシュを入れ替える
+func (v *IDTokenVerifier) refresh(ctx context.Context) error {
+	keys, ttl, err := v.fetchJWKS(ctx)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	v.keys = keys
+	v.lastFetched = now
+	v.expiresAt = now.Add(ttl)
+	return nil
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (v *IDTokenVerifier) fetchJWKS(ctx context.Context) (map[string]*rsa.PublicKey, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.JWKSURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("failed to fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, cacheTTL(resp.Header.Get("Cache-Control")), nil
+}
+
+// cacheTTL: Cache-Control の max-age を読む (Google の JWKS は max-age 付きで返ってくる)
+func cacheTTL(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age=")
+		if !ok {
+			continue
+		}
+		if sec, err := strconv.Atoi(value); err == nil && sec > 0 {
+			return time.Duration(sec) * time.Second
+		}
+	}
+	return defaultJWKSCacheTTL
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testAudience = "test-client-id.apps.googleusercontent.com"
+
+// stubJWKS: テスト用の JWKS エンドポイント。keys を差し替えると鍵のローテーションを再現できる
+type stubJWKS struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func (s *stubJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+
+	type jwk struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range s.keys {
+		set.Keys = append(set.Keys, jwk{
+			Kid: kid,
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(set)
+}
+
+func (s *stubJWKS) setKey(kid string, key *rsa.PrivateKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = map[string]*rsa.PrivateKey{kid: key}
+}
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signIDToken(t *testing.T, key *rsa.PrivateKey, kid string, claims IDTokenClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims() IDTokenClaims {
+	now := time.Now()
+	return IDTokenClaims{
+		Email:         "taro@example.com",
+		EmailVerified: true,
+		Name:          "Taro",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://accounts.google.com",
+			Subject:   "1234567890",
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func TestIDTokenVerifier_Verify(t *testing.T) {
+	key := newTestKey(t)
+	stub := &stubJWKS{}
+	stub.setKey("key-1", key)
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	verifier := NewIDTokenVerifier(server.URL, GoogleIssuers, testAudience)
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		modify  func(c *IDTokenClaims)
+		wantErr error
+	}{
+		{"正常", key, func(c *IDTokenClaims) {}, nil},
+		{"aud 違い", key, func(c *IDTokenClaims) { c.Audience = jwt.ClaimStrings{"other"} }, ErrTokenInvalid},
+		{"iss 違い", key, func(c *IDTokenClaims) { c.Issuer = "https://evil.example.com" }, ErrTokenInvalid},
+		{"期限切れ", key, func(c *IDTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }, ErrTokenExpired},
+		{"exp なし", key, func(c *IDTokenClaims) { c.ExpiresAt = nil }, ErrTokenInvalid},
+		{"別の鍵で署名", newTestKey(t), func(c *IDTokenClaims) {}, ErrTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(&claims)
+			got, err := verifier.Verify(context.Background(), signIDToken(t, tt.key, "key-1", claims))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != "1234567890" || got.Email != "taro@example.com" {
+				t.Errorf("claims = %+v", got)
+			}
+		})
+	}
+
+	// 公開鍵はキャッシュされる
+	if stub.fetches != 1 {
+		t.Errorf("fetches = %d, want 1 (cached)", stub.fetches)
+	}
+}
+
+func TestIDTokenVerifier_KeyRotation(t *testing.T) {
+	stub := &stubJWKS{}
+	stub.setKey("key-1", newTestKey(t))
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	verifier := NewIDTokenVerifier(server.URL, GoogleIssuers, testAudience)
+	clock := time.Now()
+	verifier.now = func() time.Time { return clock }
+	if _, err := verifier.key(context.Background(), "key-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 鍵がローテーションされ、キャッシュに無い kid のトークンが来た
+	rotated := newTestKey(t)
+	stub.setKey("key-2", rotated)
+	token := signIDToken(t, rotated, "key-2", validClaims())
+
+	// 直前に取得したばかりなら取り直さない
+	if _, err := verifier.Verify(context.Background(), token); err == nil {
+		t.Fatal("expected unknown kid error right after fetch")
+	}
+
+	// 少し時間が経てば取り直して検証できる
+	clock = clock.Add(2 * minJWKSRefreshInterval)
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatalf("after rotation: %v", err)
+	}
+	if stub.fetches != 2 {
+		t.Errorf("fetches = %d, want 2", stub.fetches)
+	}
+}
+
+// JWKS が取れないのはトークンのせいではないので、invalid_token (401) にしない
+func TestIDTokenVerifier_JWKSUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	verifier := NewIDTokenVerifier(server.URL, GoogleIssuers, testAudience)
+	_, err := verifier.Verify(context.Background(), signIDToken(t, newTestKey(t), "key-1", validClaims()))
+	if !errors.Is(err, ErrJWKSUnavailable) {
+		t.Fatalf("err = %v, want %v", err, ErrJWKSUnavailable)
+	}
+	if errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("err = %v, should not be invalid_token", err)
+	}
+}
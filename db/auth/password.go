@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher: bcrypt でパスワードをハッシュ化・照合する
+// bcrypt はハッシュ値の中にユーザーごとのソルトとコストを含むので、別カラムは不要
+type PasswordHasher struct {
+	Cost int
+}
+
+// NewPasswordHasher: cost が bcrypt の範囲外(0 含む)ならデフォルトコストを使う
+func NewPasswordHasher(cost int) *PasswordHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &PasswordHasher{Cost: cost}
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify: 保存値とパスワードを照合する
+// needsRehash が true のときは、照合成功後に Hash し直して保存すること
+// (平文で保存されている旧データ、またはコストが現在の設定と違うハッシュ)
+func (h *PasswordHasher) Verify(stored, password string) (ok bool, needsRehash bool) {
+	if stored == "" {
+		// ソーシャルログイン専用ユーザーなど、パスワードを持たないアカウント
+		return false, false
+	}
+
+	if !IsHashed(stored) {
+		// 旧データ(平文)との比較。タイミング攻撃対策で定数時間比較を使う
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost != h.Cost
+}
+
+// IsHashed: 保存値が bcrypt ハッシュかどうか ($2a$ / $2b$ / $2y$ で始まる 60 文字)
+func IsHashed(stored string) bool {
+	if len(stored) != 60 {
+		return false
+	}
+	return strings.HasPrefix(stored, "$2a$") ||
+		strings.HasPrefix(stored, "$2b$") ||
+		strings.HasPrefix(stored, "$2y$")
+}
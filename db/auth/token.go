@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"db/apperr"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrTokenExpired = apperr.New(apperr.Unauthorized, "token_expired", "token expired")
+	ErrTokenInvalid = apperr.New(apperr.Unauthorized, "invalid_token", "invalid token")
+)
+
+// AccessClaims: アクセストークン(JWT)の中身
+// sid はセッションID。ログアウト済みセッションのトークンを弾くのに使う
+type AccessClaims struct {
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// TokenIssuer: HMAC(HS256) 署名付きアクセストークンの発行・検証
+type TokenIssuer struct {
+	secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	now        func() time.Time
+}
+
+func NewTokenIssuer(secret []byte, accessTTL, refreshTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		secret:     secret,
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+		now:        time.Now,
+	}
+}
+
+// IssueAccessToken: ユーザーIDとセッションIDを埋め込んだアクセストークンを発行する
+func (t *TokenIssuer) IssueAccessToken(userID int, sessionID string) (string, time.Time, error) {
+	now := t.now()
+	expiresAt := now.Add(t.AccessTTL)
+	claims := AccessClaims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseAccessToken: 署名と有効期限を検証し、ユーザーIDとセッションIDを返す
+func (t *TokenIssuer) ParseAccessToken(token string) (int, string, error) {
+	var claims AccessClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(t.now))
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return 0, "", ErrTokenExpired
+	}
+	if err != nil {
+		return 0, "", ErrTokenInvalid
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID == 0 || claims.SessionID == "" {
+		return 0, "", ErrTokenInvalid
+	}
+	return userID, claims.SessionID, nil
+}
+
+// NewRefreshToken: リフレッシュトークン(推測不能なランダム文字列)を作る
+// DB には HashRefreshToken した値だけを保存する
+func NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSessionID: セッションIDを作る
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
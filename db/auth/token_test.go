@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenIssuer_ParseAccessToken(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("secret"), time.Minute, time.Hour)
+	token, _, err := issuer.IssueAccessToken(42, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, sessionID, err := issuer.ParseAccessToken(token)
+	if err != nil || userID != 42 || sessionID != "session-1" {
+		t.Fatalf("got (%d, %q, %v), want (42, session-1, nil)", userID, sessionID, err)
+	}
+
+	// 別の鍵で署名されたトークンは不正
+	other := NewTokenIssuer([]byte("other"), time.Minute, time.Hour)
+	if _, _, err := other.ParseAccessToken(token); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("other key: err = %v, want ErrTokenInvalid", err)
+	}
+
+	// 期限切れ
+	issuer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, _, err := issuer.ParseAccessToken(token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v, want ErrTokenExpired", err)
+	}
+}
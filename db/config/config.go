@@ -0,0 +1,380 @@
+// Package config はサーバーの設定をまとめて読み込む
+//
+// 読み込む順番 (後のものが優先):
+//  1. 組み込みのデフォルト
+//  2. 設定ファイル (JSON)。CONFIG_FILE で指定するか、config.<APP_ENV>.json があればそれを使う
+//  3. 環境変数 (MYSQL_USER など)
+//
+// APP_ENV (local / staging / production、デフォルト local) で必須の値が変わる
+// 足りない値は Load がまとめてエラーにする (1つずつ直して再起動しなくて済むように)
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EnvLocal      = "local"
+	EnvStaging    = "staging"
+	EnvProduction = "production"
+)
+
+type Config struct {
+	Env            string `json:"-"`
+	Port           string `json:"port"`
+	MigrateOnStart bool   `json:"migrate_on_start"`
+
+	MySQL   MySQL   `json:"mysql"`
+	Auth    Auth    `json:"auth"`
+	Images  Images  `json:"images"`
+	CORS    CORS    `json:"cors"`
+	Server  Server  `json:"server"`
+	Log     Log     `json:"log"`
+	Metrics Metrics `json:"metrics"`
+	Tracing Tracing `json:"tracing"`
+	Gemini  Gemini  `json:"gemini"`
+	Help    Help    `json:"help"`
+
+	source string   // 読み込んだ設定ファイル (ログ用)
+	errs   []string // 読み込み中のエラー (最後にまとめて返す)
+}
+
+type MySQL struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Host     string `json:"host"` // "/" で始まれば Unix ソケット (Cloud Run)
+	Database string `json:"database"`
+}
+
+type Auth struct {
+	// アクセストークンの署名鍵。local で空ならランダムに作る (再起動でトークンが無効になる)
+	TokenSecret      string `json:"token_secret"`
+	PasswordHashCost int    `json:"password_hash_cost"` // 0 なら bcrypt のデフォルト
+	GoogleClientID   string `json:"google_client_id"`
+	GoogleJWKSURL    string `json:"google_jwks_url"`
+}
+
+type Images struct {
+	Bucket string `json:"bucket"` // あれば Cloud Storage に保存する
+	Dir    string `json:"dir"`    // Bucket がないときのローカルの保存先
+}
+
+type CORS struct {
+	AllowedOrigins   []string `json:"allowed_origins"` // "*" なら全部
+	AllowCredentials bool     `json:"allow_credentials"`
+}
+
+type Server struct {
+	ShutdownDelay   Duration `json:"shutdown_delay"`   // /readyz を 503 にしてから新しい接続を断るまで
+	ShutdownTimeout Duration `json:"shutdown_timeout"` // 処理中のリクエストを待つ時間
+}
+
+// Log: ログの出し方 (Cloud Logging が読めるように、デフォルトは JSON)
+type Log struct {
+	Level  string `json:"level"`  // debug / info / warn / error
+	Format string `json:"format"` // json / text (手元で読むなら text)
+}
+
+// Metrics: /metrics (Prometheus)
+type Metrics struct {
+	Token string `json:"token"` // あれば Authorization: Bearer <Token> を必須にする
+}
+
+// Tracing: OpenTelemetry のトレース
+type Tracing struct {
+	Exporter    string  `json:"exporter"`     // none / stdout / otlp
+	Endpoint    string  `json:"endpoint"`     // otlp の送り先 ("localhost:4318" や "https://collector.example/v1/traces")
+	SampleRatio float64 `json:"sample_ratio"` // 0〜1 (呼び出し元がサンプリング済みならそれに従う)
+	ServiceName string  `json:"service_name"`
+}
+
+// Gemini: 商品説明の生成・価格の査定 (Vertex AI)
+type Gemini struct {
+	ProjectID string `json:"project_id"`
+	Location  string `json:"location"`
+	Model     string `json:"model"`
+}
+
+// Help: ヘルプ検索 (Vertex AI Search)
+type Help struct {
+	ProjectID string `json:"project_id"`
+	Location  string `json:"location"`
+	EngineID  string `json:"engine_id"`
+}
+
+// Duration: 設定ファイルでは "10s" のように書く
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// defaults: 組み込みのデフォルト
+// 環境ごとに違うのは、必須の値 (validate) と config.<env>.json の中身、それと手元用の GCP の設定
+func defaults(env string) *Config {
+	c := &Config{
+		Env:    env,
+		Port:   "8080",
+		Images: Images{Dir: "uploads"},
+		CORS:   CORS{AllowedOrigins: []string{"*"}},
+		Server: Server{
+			// Cloud Run は SIGTERM の 10 秒後に強制終了するので、合計がそれより短くなるようにする
+			ShutdownDelay:   Duration(2 * time.Second),
+			ShutdownTimeout: Duration(7 * time.Second),
+		},
+		Log: Log{Level: "info", Format: "json"},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "hackathon-api",
+		},
+	}
+	if env == EnvLocal {
+		// 手元では開発用の GCP プロジェクトをそのまま使う (本番・ステージングでは必ず指定させる)
+		c.Gemini = Gemini{
+			ProjectID: "term8-naoto-takaku",
+			Location:  "asia-northeast1",
+			Model:     "gemini-2.5-flash",
+		}
+		c.Help = Help{
+			ProjectID: "term8-naoto-takaku",
+			Location:  "global",
+			EngineID:  "hackathon-manual-help_1766104642390",
+		}
+	}
+	return c
+}
+
+// Load: 環境変数 (getenv) から設定を読み込んで検証する
+func Load(getenv func(string) string) (*Config, error) {
+	env := getenv("APP_ENV")
+	if env == "" {
+		env = EnvLocal
+	}
+	if env != EnvLocal && env != EnvStaging && env != EnvProduction {
+		return nil, fmt.Errorf("config: APP_ENV must be one of local, staging, production (got %q)", env)
+	}
+	c := defaults(env)
+
+	path, required := getenv("CONFIG_FILE"), true
+	if path == "" {
+		path, required = "config."+env+".json", false
+	}
+	if err := c.loadFile(path, required); err != nil {
+		return nil, err
+	}
+	c.loadEnv(getenv)
+	c.validate()
+
+	if len(c.errs) > 0 {
+		return nil, fmt.Errorf("config (%s):\n  - %s", env, strings.Join(c.errs, "\n  - "))
+	}
+	return c, nil
+}
+
+// Source: どの設定ファイルを読んだか ("" なら環境変数だけ)
+func (c *Config) Source() string {
+	return c.source
+}
+
+func (c *Config) loadFile(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // 書き間違いに気付けるように
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	c.source = path
+	return nil
+}
+
+func (c *Config) loadEnv(getenv func(string) string) {
+	c.str(getenv, "PORT", &c.Port)
+	c.boolean(getenv, "MIGRATE_ON_START", &c.MigrateOnStart)
+
+	c.str(getenv, "MYSQL_USER", &c.MySQL.User)
+	c.str(getenv, "MYSQL_PASSWORD", &c.MySQL.Password)
+	c.str(getenv, "MYSQL_HOST", &c.MySQL.Host)
+	c.str(getenv, "MYSQL_DATABASE", &c.MySQL.Database)
+
+	c.str(getenv, "AUTH_TOKEN_SECRET", &c.Auth.TokenSecret)
+	c.integer(getenv, "PASSWORD_HASH_COST", &c.Auth.PasswordHashCost)
+	c.str(getenv, "GOOGLE_CLIENT_ID", &c.Auth.GoogleClientID)
+	c.str(getenv, "GOOGLE_JWKS_URL", &c.Auth.GoogleJWKSURL)
+
+	c.str(getenv, "IMAGE_BUCKET", &c.Images.Bucket)
+	c.str(getenv, "IMAGE_DIR", &c.Images.Dir)
+
+	if v := getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		c.CORS.AllowedOrigins = nil
+		for _, o := range strings.Split(v, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				c.CORS.AllowedOrigins = append(c.CORS.AllowedOrigins, strings.TrimSuffix(o, "/"))
+			}
+		}
+	}
+	c.boolean(getenv, "CORS_ALLOW_CREDENTIALS", &c.CORS.AllowCredentials)
+
+	c.duration(getenv, "SHUTDOWN_DELAY", &c.Server.ShutdownDelay)
+	c.duration(getenv, "SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	c.str(getenv, "LOG_LEVEL", &c.Log.Level)
+	c.str(getenv, "LOG_FORMAT", &c.Log.Format)
+	c.str(getenv, "METRICS_TOKEN", &c.Metrics.Token)
+
+	c.str(getenv, "TRACING_EXPORTER", &c.Tracing.Exporter)
+	c.str(getenv, "OTEL_EXPORTER_OTLP_ENDPOINT", &c.Tracing.Endpoint)
+	c.float(getenv, "TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+	c.str(getenv, "OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+
+	c.str(getenv, "GEMINI_PROJECT_ID", &c.Gemini.ProjectID)
+	c.str(getenv, "GEMINI_LOCATION", &c.Gemini.Location)
+	c.str(getenv, "GEMINI_MODEL", &c.Gemini.Model)
+
+	c.str(getenv, "HELP_PROJECT_ID", &c.Help.ProjectID)
+	c.str(getenv, "HELP_LOCATION", &c.Help.Location)
+	c.str(getenv, "HELP_ENGINE_ID", &c.Help.EngineID)
+}
+
+// validate: 足りない値・おかしな値を全部 errs に集める
+func (c *Config) validate() {
+	required := map[string]string{
+		"MYSQL_USER":     c.MySQL.User,
+		"MYSQL_PASSWORD": c.MySQL.Password,
+		"MYSQL_DATABASE": c.MySQL.Database,
+	}
+	if c.Env != EnvLocal {
+		// 本番・ステージングではインスタンス間でトークンが通るように、署名鍵と画像の保存先を必須にする
+		required["AUTH_TOKEN_SECRET"] = c.Auth.TokenSecret
+		required["GOOGLE_CLIENT_ID"] = c.Auth.GoogleClientID
+		required["IMAGE_BUCKET"] = c.Images.Bucket
+		// Gemini・ヘルプ検索のデフォルトは手元用なので、どの GCP プロジェクトを使うかも明示させる
+		required["GEMINI_PROJECT_ID"] = c.Gemini.ProjectID
+		required["GEMINI_LOCATION"] = c.Gemini.Location
+		required["GEMINI_MODEL"] = c.Gemini.Model
+		required["HELP_PROJECT_ID"] = c.Help.ProjectID
+		required["HELP_LOCATION"] = c.Help.Location
+		required["HELP_ENGINE_ID"] = c.Help.EngineID
+	}
+	var missing []string
+	for name, v := range required {
+		if v == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		c.errs = append(c.errs, "missing required values: "+strings.Join(missing, ", "))
+	}
+
+	if c.Images.Bucket == "" && c.Images.Dir == "" {
+		c.errs = append(c.errs, "IMAGE_BUCKET or IMAGE_DIR is required")
+	}
+	if c.CORS.AllowCredentials && len(c.CORS.AllowedOrigins) == 0 {
+		c.errs = append(c.errs, "CORS_ALLOW_CREDENTIALS requires CORS_ALLOWED_ORIGINS")
+	}
+	if c.Server.ShutdownDelay < 0 || c.Server.ShutdownTimeout <= 0 {
+		c.errs = append(c.errs, "SHUTDOWN_DELAY must be >= 0 and SHUTDOWN_TIMEOUT must be > 0")
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		c.errs = append(c.errs, fmt.Sprintf("LOG_LEVEL must be debug, info, warn or error (got %q)", c.Log.Level))
+	}
+	if f := strings.ToLower(c.Log.Format); f != "json" && f != "text" {
+		c.errs = append(c.errs, fmt.Sprintf("LOG_FORMAT must be json or text (got %q)", c.Log.Format))
+	}
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			c.errs = append(c.errs, "TRACING_EXPORTER=otlp requires OTEL_EXPORTER_OTLP_ENDPOINT")
+		}
+	default:
+		c.errs = append(c.errs, fmt.Sprintf("TRACING_EXPORTER must be none, stdout or otlp (got %q)", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		c.errs = append(c.errs, fmt.Sprintf("TRACING_SAMPLE_RATIO must be between 0 and 1 (got %v)", c.Tracing.SampleRatio))
+	}
+}
+
+func (c *Config) str(getenv func(string) string, name string, dst *string) {
+	if v := getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+func (c *Config) boolean(getenv func(string) string, name string, dst *bool) {
+	v := getenv(name)
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Sprintf("%s must be true or false (got %q)", name, v))
+		return
+	}
+	*dst = b
+}
+
+func (c *Config) integer(getenv func(string) string, name string, dst *int) {
+	v := getenv(name)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Sprintf("%s must be an integer (got %q)", name, v))
+		return
+	}
+	*dst = n
+}
+
+func (c *Config) float(getenv func(string) string, name string, dst *float64) {
+	v := getenv(name)
+	if v == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Sprintf("%s must be a number (got %q)", name, v))
+		return
+	}
+	*dst = f
+}
+
+func (c *Config) duration(getenv func(string) string, name string, dst *Duration) {
+	v := getenv(name)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Sprintf("%s must be a duration like 10s (got %q)", name, v))
+		return
+	}
+	*dst = Duration(d)
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envMap: テスト用の getenv
+func envMap(m map[string]string) func(string) string {
+	return func(name string) string { return m[name] }
+}
+
+func baseEnv() map[string]string {
+	return map[string]string{
+		"MYSQL_USER":     "app",
+		"MYSQL_PASSWORD": "secret",
+		"MYSQL_DATABASE": "hackathon",
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := Load(envMap(baseEnv()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Env != EnvLocal || c.Port != "8080" || c.Images.Dir != "uploads" {
+		t.Errorf("defaults = %+v", c)
+	}
+	if time.Duration(c.Server.ShutdownTimeout) != 7*time.Second {
+		t.Errorf("ShutdownTimeout = %v", time.Duration(c.Server.ShutdownTimeout))
+	}
+	if c.Gemini.Model == "" || c.Help.EngineID == "" {
+		t.Errorf("Gemini / Help のデフォルトがない: %+v %+v", c.Gemini, c.Help)
+	}
+}
+
+// 足りない値は1回のエラーで全部わかる
+func TestLoadListsAllMissing(t *testing.T) {
+	_, err := Load(envMap(map[string]string{"APP_ENV": "production"}))
+	if err == nil {
+		t.Fatal("エラーになるはず")
+	}
+	want := "missing required values: AUTH_TOKEN_SECRET, GEMINI_LOCATION, GEMINI_MODEL, GEMINI_PROJECT_ID, GOOGLE_CLIENT_ID, " +
+		"HELP_ENGINE_ID, HELP_LOCATION, HELP_PROJECT_ID, IMAGE_BUCKET, MYSQL_DATABASE, MYSQL_PASSWORD, MYSQL_USER"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestLoadProductionRequired(t *testing.T) {
+	env := baseEnv()
+	env["APP_ENV"] = "production"
+	env["AUTH_TOKEN_SECRET"] = "s"
+	env["GOOGLE_CLIENT_ID"] = "client"
+	env["IMAGE_BUCKET"] = "bucket"
+	env["GEMINI_PROJECT_ID"] = "prod-project"
+	env["GEMINI_LOCATION"] = "asia-northeast1"
+	env["GEMINI_MODEL"] = "gemini-2.5-flash"
+	env["HELP_PROJECT_ID"] = "prod-project"
+	env["HELP_LOCATION"] = "global"
+	env["HELP_ENGINE_ID"] = "engine"
+	c, err := Load(envMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Gemini.ProjectID != "prod-project" || c.Help.EngineID != "engine" {
+		t.Errorf("Gemini / Help = %+v %+v", c.Gemini, c.Help)
+	}
+}
+
+func TestLoadUnknownEnv(t *testing.T) {
+	env := baseEnv()
+	env["APP_ENV"] = "prod"
+	if _, err := Load(envMap(env)); err == nil {
+		t.Fatal("知らない APP_ENV はエラーになるはず")
+	}
+}
+
+func TestLoadInvalidValues(t *testing.T) {
+	env := baseEnv()
+	env["MIGRATE_ON_START"] = "yes please"
+	env["SHUTDOWN_DELAY"] = "2"
+	env["PASSWORD_HASH_COST"] = "high"
+	env["LOG_FORMAT"] = "xml"
+	env["TRACING_SAMPLE_RATIO"] = "1.5"
+	_, err := Load(envMap(env))
+	if err == nil {
+		t.Fatal("エラーになるはず")
+	}
+	for _, name := range []string{"MIGRATE_ON_START", "SHUTDOWN_DELAY", "PASSWORD_HASH_COST", "LOG_FORMAT", "TRACING_SAMPLE_RATIO"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("err に %s がない: %v", name, err)
+		}
+	}
+}
+
+func TestLoadCORSOrigins(t *testing.T) {
+	env := baseEnv()
+	env["CORS_ALLOWED_ORIGINS"] = " https://a.example/ , ,https://b.example"
+	env["CORS_ALLOW_CREDENTIALS"] = "true"
+	c, err := Load(envMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(c.CORS.AllowedOrigins, ",")
+	if got != "https://a.example,https://b.example" || !c.CORS.AllowCredentials {
+		t.Errorf("CORS = %+v", c.CORS)
+	}
+}
+
+// 設定ファイルより環境変数が優先
+func TestLoadFileThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{
+		"port": "9000",
+		"mysql": {"user": "file-user", "password": "file-pass", "database": "file-db"},
+		"server": {"shutdown_delay": "0s"},
+		"gemini": {"model": "gemini-test"}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(envMap(map[string]string{"CONFIG_FILE": path, "MYSQL_USER": "env-user"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Source() != path {
+		t.Errorf("Source = %q", c.Source())
+	}
+	if c.Port != "9000" || c.MySQL.User != "env-user" || c.MySQL.Password != "file-pass" {
+		t.Errorf("config = %+v", c)
+	}
+	if c.Gemini.Model != "gemini-test" || c.Gemini.Location != "asia-northeast1" {
+		t.Errorf("Gemini = %+v (ファイルにない値はデフォルトのまま)", c.Gemini)
+	}
+	if c.Server.ShutdownDelay != 0 {
+		t.Errorf("ShutdownDelay = %v", time.Duration(c.Server.ShutdownDelay))
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	typo := filepath.Join(dir, "typo.json")
+	os.WriteFile(typo, []byte(`{"mysql": {"usr": "app"}}`), 0o600)
+
+	for _, path := range []string{filepath.Join(dir, "missing.json"), typo} {
+		env := baseEnv()
+		env["CONFIG_FILE"] = path
+		if _, err := Load(envMap(env)); err == nil {
+			t.Errorf("%s: エラーになるはず", path)
+		}
+	}
+}
+
+func TestLoadTracing(t *testing.T) {
+	env := baseEnv()
+	env["TRACING_EXPORTER"] = "otlp"
+	if _, err := Load(envMap(env)); err == nil || !strings.Contains(err.Error(), "OTEL_EXPORTER_OTLP_ENDPOINT") {
+		t.Errorf("endpoint なしの otlp はエラーになるはず: %v", err)
+	}
+
+	env["OTEL_EXPORTER_OTLP_ENDPOINT"] = "localhost:4318"
+	env["TRACING_SAMPLE_RATIO"] = "0.1"
+	c, err := Load(envMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Tracing.Endpoint != "localhost:4318" || c.Tracing.SampleRatio != 0.1 || c.Tracing.ServiceName == "" {
+		t.Errorf("Tracing = %+v", c.Tracing)
+	}
+}
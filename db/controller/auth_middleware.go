@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"db/auth"
+	"db/logging"
+	"db/usecase"
+)
+
+// AuthMiddleware: Authorization: Bearer <token> を検証し、呼び出し元を context に入れる
+type AuthMiddleware struct {
+	Sessions *usecase.SessionUsecase
+}
+
+func NewAuthMiddleware(s *usecase.SessionUsecase) *AuthMiddleware {
+	return &AuthMiddleware{Sessions: s}
+}
+
+// Wrap: トークンがあれば検証して context に入れる。不正なトークンなら 401
+// トークンが無いリクエストはそのまま通す (一覧取得など公開APIのため)
+// ログインが必要かどうかは各ハンドラーで requireUser を使って判定する
+func (m *AuthMiddleware) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			writeError(w, r, errBadAuthorization)
+			return
+		}
+
+		// 期限切れ・不正なトークン・ログアウト済みのセッションは 401、DB のエラーなどは 500
+		userID, sessionID, err := m.Sessions.Authenticate(r.Context(), token)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logging.SetUserID(r.Context(), userID)
+		next(w, r.WithContext(auth.WithUser(r.Context(), userID, sessionID)))
+	}
+}
+
+// requireUser: ログイン必須のAPIで使う。未ログインなら 401 を書き込んで false を返す
+func requireUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, r, errLoginRequired)
+		return 0, false
+	}
+	return userID, true
+}
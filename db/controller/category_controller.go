@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"db/usecase"
+	"encoding/json"
+	"net/http"
+)
+
+type CategoryController struct {
+	Usecase *usecase.CategoryUsecase
+}
+
+func NewCategoryController(u *usecase.CategoryUsecase) *CategoryController {
+	return &CategoryController{Usecase: u}
+}
+
+// RegisterRoutes: カテゴリの API。作成・編集・削除は管理者だけ
+func (c *CategoryController) RegisterRoutes(rt *Router) {
+	rt.HandleAuth("GET /api/categories", c.getTree)
+	rt.HandleAuth("POST /api/categories", c.createCategory)
+	rt.HandleAuth("PATCH /api/categories/{id}", c.updateCategory)
+	rt.HandleAuth("DELETE /api/categories/{id}", c.deleteCategory)
+}
+
+func (c *CategoryController) getTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := c.Usecase.GetTree(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+func (c *CategoryController) createCategory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	var req usecase.CategoryReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	id, err := c.Usecase.CreateCategory(r.Context(), userID, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func (c *CategoryController) updateCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "category")
+	if !ok {
+		return
+	}
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	var req usecase.CategoryReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := c.Usecase.UpdateCategory(r.Context(), userID, id, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+}
+
+func (c *CategoryController) deleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "category")
+	if !ok {
+		return
+	}
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	if err := c.Usecase.DeleteCategory(r.Context(), userID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
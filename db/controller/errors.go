@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"db/apperr"
+	"db/logging"
+)
+
+// エラーのレスポンスはどの API でも同じ形の JSON にする:
+//
+//	{"code": "item_not_found", "message": "商品が見つかりません", "details": {}, "request_id": "..."}
+//
+// code は apperr のコード (フロントはこれで分岐する)、message は Accept-Language に合わせた文言 (ja / en、デフォルト ja)
+// details は apperr.Error.With で付けた詳細 ("field" など)
+// apperr でないエラー (DB など) はログにだけ出し、中身は返さない (SQL などが漏れないように)
+type errorResponse struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details"`
+	RequestID string         `json:"request_id"`
+}
+
+// コントローラーで起きるエラー (ユースケースのものは usecase パッケージにある)
+var (
+	errInvalidJSON      = apperr.New(apperr.Validation, "invalid_json", "invalid json")
+	errInvalidID        = apperr.New(apperr.Validation, "invalid_id", "invalid id")
+	errBodyTooLarge     = apperr.New(apperr.TooLarge, "body_too_large", "request body is too large")
+	errLoginRequired    = apperr.New(apperr.Unauthorized, "login_required", "login required")
+	errBadAuthorization = apperr.New(apperr.Unauthorized, "invalid_authorization", "authorization header must be Bearer token")
+	errImageRequired    = apperr.New(apperr.Validation, "image_required", `multipart field "image" is required`)
+	errAIFailed         = apperr.New(apperr.Upstream, "ai_failed", "AI request failed")
+	errTimeout          = apperr.New(apperr.Upstream, "timeout", "request timed out")
+	errNotFound         = apperr.New(apperr.NotFound, "not_found", "not found")
+	errMethodNotAllowed = apperr.New(apperr.Validation, "method_not_allowed", "method not allowed")
+	errInternal         = apperr.New(apperr.Internal, "internal_error", "internal server error")
+)
+
+var kindStatus = map[apperr.Kind]int{
+	apperr.NotFound:     http.StatusNotFound,
+	apperr.Validation:   http.StatusBadRequest,
+	apperr.Conflict:     http.StatusConflict,
+	apperr.Unauthorized: http.StatusUnauthorized,
+	apperr.Forbidden:    http.StatusForbidden,
+	apperr.TooLarge:     http.StatusRequestEntityTooLarge,
+	apperr.Unsupported:  http.StatusUnsupportedMediaType,
+	apperr.Upstream:     http.StatusBadGateway,
+}
+
+// writeError: err の種類からステータスを決めて、エラーの JSON を書く
+// 想定外のエラーはリクエストID付きでログに出してから 500 にする
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	e := apperr.From(err)
+	switch {
+	case e != nil && e.Kind != apperr.Internal:
+	case errors.As(err, &tooLarge):
+		e = errBodyTooLarge.With("max_bytes", tooLarge.Limit)
+	case errors.Is(err, context.DeadlineExceeded):
+		// ルートのタイムアウト (Timeout) で DB や外部 API の呼び出しが打ち切られた
+		slog.WarnContext(r.Context(), "request timed out", "error", err)
+		writeErrorStatus(w, r, http.StatusGatewayTimeout, errTimeout)
+		return
+	default:
+		slog.ErrorContext(r.Context(), "internal error", "error", err)
+		e = errInternal
+	}
+
+	status, ok := kindStatus[e.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	if status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+		w.Header().Set("WWW-Authenticate", bearerChallenge(e.Code))
+	}
+	writeErrorStatus(w, r, status, e)
+}
+
+// writeErrorStatus: ステータスを指定してエラーの JSON を書く (404 / 405 など、ステータスが先に決まっているとき)
+func writeErrorStatus(w http.ResponseWriter, r *http.Request, status int, e *apperr.Error) {
+	details := e.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", preferredLanguage(r))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Code:      e.Code,
+		Message:   errorMessage(e, preferredLanguage(r)),
+		Details:   details,
+		RequestID: logging.RequestID(r.Context()),
+	})
+}
+
+// bearerChallenge: 401 の WWW-Authenticate (RFC 6750)
+// トークンがない・ログインしていないだけなら error は付けない
+func bearerChallenge(code string) string {
+	switch code {
+	case "invalid_token", "token_expired", "session_expired":
+		return `Bearer error="invalid_token", error_description="` + code + `"`
+	case "invalid_authorization":
+		return `Bearer error="invalid_request"`
+	}
+	return "Bearer"
+}
+
+// decodeJSON: リクエストボディの JSON を dst に読む。読めなければエラーを書き込んで false を返す
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, err)
+		} else {
+			writeError(w, r, errInvalidJSON)
+		}
+		return false
+	}
+	return true
+}
+
+// aiError: Gemini・Vertex AI Search の失敗は 502 (ai_failed) にする
+// ルートのタイムアウトで打ち切られたときはそのまま返す (writeError が 504 にする)
+func aiError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return errAIFailed.Wrap(err)
+}
+
+// preferredLanguage: Accept-Language から "ja" か "en" を選ぶ (どちらもなければ ja)
+func preferredLanguage(r *http.Request) string {
+	best, bestQ := "ja", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (lang == "ja" || lang == "en") && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// errorMessage: code の文言。カタログになければ種類ごとの文言
+func errorMessage(e *apperr.Error, lang string) string {
+	m, ok := errorMessages[e.Code]
+	if !ok {
+		m = kindMessages[e.Kind]
+	}
+	if lang == "en" {
+		return m.en
+	}
+	return m.ja
+}
+
+type localized struct {
+	ja, en string
+}
+
+var kindMessages = map[apperr.Kind]localized{
+	apperr.Internal:     {"サーバーでエラーが発生しました。時間をおいてもう一度お試しください", "Something went wrong. Please try again later."},
+	apperr.NotFound:     {"見つかりませんでした", "Not found."},
+	apperr.Validation:   {"入力内容を確認してください", "Please check your input."},
+	apperr.Conflict:     {"現在の状態ではこの操作はできません", "This action can't be done in the current state."},
+	apperr.Unauthorized: {"ログインしてください", "Please log in."},
+	apperr.Forbidden:    {"この操作をする権限がありません", "You don't have permission to do this."},
+	apperr.TooLarge:     {"サイズが大きすぎます", "Too large."},
+	apperr.Unsupported:  {"対応していない形式です", "Unsupported format."},
+	apperr.Upstream:     {"外部サービスでエラーが発生しました。時間をおいてもう一度お試しください", "An external service failed. Please try again later."},
+}
+
+// errorMessages: code ごとの文言 (apperr.New したら、ここにも足す)
+var errorMessages = map[string]localized{
+	// コントローラー
+	"invalid_json":          {"リクエストの形式が正しくありません", "The request body is not valid JSON."},
+	"invalid_id":            {"ID が正しくありません", "Invalid ID."},
+	"body_too_large":        {"リクエストが大きすぎます", "The request body is too large."},
+	"login_required":        {"ログインしてください", "Please log in."},
+	"invalid_authorization": {"認証ヘッダーの形式が正しくありません", "The Authorization header must be a Bearer token."},
+	"image_required":        {"画像を選んでください", "Please attach an image."},
+	"ai_failed":             {"AI の処理に失敗しました。時間をおいてもう一度お試しください", "The AI request failed. Please try again later."},
+	"timeout":               {"処理に時間がかかりすぎたため中断しました", "The request timed out."},
+	"not_found":             {"ページが見つかりません", "Not found."},
+	"method_not_allowed":    {"このメソッドは使えません", "Method not allowed."},
+	"internal_error":        {"サーバーでエラーが発生しました。時間をおいてもう一度お試しください", "Something went wrong. Please try again later."},
+
+	// 認証
+	"invalid_token":       {"ログインの有効期限が切れたか、無効です。もう一度ログインしてください", "Your login is invalid. Please log in again."},
+	"token_expired":       {"ログインの有効期限が切れました", "Your login has expired."},
+	"unauthenticated":     {"もう一度ログインしてください", "Please log in again."},
+	"session_expired":     {"ログインの有効期限が切れました。もう一度ログインしてください", "Your session has expired. Please log in again."},
+	"invalid_credentials": {"名前またはパスワードが違います", "Incorrect name or password."},
+	"jwks_unavailable":    {"Google ログインを確認できませんでした。時間をおいてもう一度お試しください", "Could not verify your Google login. Please try again later."},
+
+	// ユーザー
+	"user_not_found":  {"ユーザーが見つかりません", "User not found."},
+	"invalid_user":    {"入力内容を確認してください", "Please check your input."},
+	"email_in_use":    {"このメールアドレスは既に登録されています", "This email address is already registered."},
+	"identity_in_use": {"この Google アカウントは別のユーザーに紐付いています", "This Google account is linked to another user."},
+
+	// 商品
+	"item_not_found":       {"商品が見つかりません", "Item not found."},
+	"invalid_item":         {"商品の内容を確認してください", "Please check the item details."},
+	"invalid_query":        {"検索条件が正しくありません", "Invalid search conditions."},
+	"invalid_cursor":       {"ページの指定が正しくありません", "Invalid page cursor."},
+	"not_item_seller":      {"出品者だけが変更できます", "Only the seller can change this item."},
+	"item_not_editable":    {"この商品はもう編集できません", "This item can no longer be edited."},
+	"invalid_transition":   {"この商品の状態は変更できません", "The item can't change to this status."},
+	"transition_forbidden": {"この状態の変更はできません", "You are not allowed to make this status change."},
+
+	// 購入
+	"invalid_purchase":    {"購入する商品を指定してください", "Please specify the item to buy."},
+	"cannot_buy_own_item": {"自分の商品は購入できません", "You can't buy your own item."},
+
+	// カテゴリ
+	"category_not_found": {"カテゴリが見つかりません", "Category not found."},
+	"invalid_category":   {"カテゴリの内容を確認してください", "Please check the category."},
+	"category_in_use":    {"商品や子カテゴリがあるカテゴリは変更できません", "This category has items or subcategories."},
+	"admin_only":         {"管理者だけが操作できます", "Only admins can do this."},
+
+	// 画像
+	"image_not_found":    {"画像が見つかりません", "Image not found."},
+	"unknown_image_size": {"画像のサイズの指定が正しくありません (thumb, medium, full)", "Unknown image size (thumb, medium, full)."},
+	"image_too_large":    {"画像が大きすぎます", "The image is too large."},
+	"unsupported_image":  {"対応していない画像形式です (JPEG・PNG・WebP)", "Unsupported image type (JPEG, PNG and WebP only)."},
+
+	// メッセージ
+	"invalid_message": {"メッセージの内容を確認してください", "Please check your message."},
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"db/apperr"
+	"db/auth"
+	"db/media"
+	"db/usecase"
+)
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("本文が JSON でない: %v", err)
+	}
+	return body
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"見つからない", usecase.ErrItemNotFound, http.StatusNotFound, "item_not_found"},
+		{"入力が不正", usecase.ErrInvalidItem.With("field", "price"), http.StatusBadRequest, "invalid_item"},
+		{"包んでも種類は変わらない", fmt.Errorf("update: %w", usecase.ErrItemNotEditable), http.StatusConflict, "item_not_editable"},
+		{"権限がない", usecase.ErrAdminOnly, http.StatusForbidden, "admin_only"},
+		{"ログインしていない", errLoginRequired, http.StatusUnauthorized, "login_required"},
+		{"外部サービス", errAIFailed.Wrap(errors.New("quota exceeded")), http.StatusBadGateway, "ai_failed"},
+		{"画像が大きすぎる", usecase.ErrImageTooLarge, http.StatusRequestEntityTooLarge, "image_too_large"},
+		{"ボディが大きすぎる", &http.MaxBytesError{Limit: 10}, http.StatusRequestEntityTooLarge, "body_too_large"},
+		{"タイムアウト", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout"},
+		{"想定外", errors.New("Error 1146: Table 'hackathon.itemz' doesn't exist"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			body := decodeError(t, rec)
+			if body.Code != tt.wantCode || body.Message == "" || body.Details == nil {
+				t.Errorf("body = %+v", body)
+			}
+		})
+	}
+}
+
+// 想定外のエラーの中身 (SQL など) はレスポンスに出さない
+func TestWriteErrorHidesInternal(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), errors.New("query error: SELECT * FROM users"))
+	if strings.Contains(rec.Body.String(), "SELECT") {
+		t.Errorf("SQL が漏れている: %s", rec.Body.String())
+	}
+}
+
+func TestWriteErrorDetailsAndRequestID(t *testing.T) {
+	rt := NewRouter(nil)
+	rt.Use(RequestID)
+	rt.Handle("POST /api/items", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, usecase.ErrInvalidItem.With("field", "price").With("max", 9999999))
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/items", nil)
+	req.Header.Set("X-Request-ID", "req-9")
+	req.Header.Set("Accept-Language", "fr-FR, en-US;q=0.8, ja;q=0.5")
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, req)
+
+	body := decodeError(t, rec)
+	if body.RequestID != "req-9" || body.Details["field"] != "price" || body.Details["max"] != float64(9999999) {
+		t.Errorf("body = %+v", body)
+	}
+	if body.Message != errorMessages["invalid_item"].en {
+		t.Errorf("message = %q (Accept-Language は en が優先のはず)", body.Message)
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                      "ja",
+		"en":                    "en",
+		"en-US,en;q=0.9":        "en",
+		"ja,en-US;q=0.9":        "ja",
+		"en;q=0.3, ja-JP;q=0.7": "ja",
+		"fr, de":                "ja",
+	}
+	for header, want := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Language", header)
+		if got := preferredLanguage(r); got != want {
+			t.Errorf("%q: got %q, want %q", header, got, want)
+		}
+	}
+}
+
+// 401 には WWW-Authenticate を付ける (トークンが不正なら error="invalid_token")
+func TestWriteErrorChallenge(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), fmt.Errorf("%w: bad signature", auth.ErrTokenInvalid))
+	if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, `error="invalid_token"`) {
+		t.Errorf("WWW-Authenticate = %q", got)
+	}
+	rec = httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), errLoginRequired)
+	if got := rec.Header().Get("WWW-Authenticate"); got != "Bearer" {
+		t.Errorf("WWW-Authenticate = %q", got)
+	}
+}
+
+// 空のメッセージは 500 ではなく 400
+func TestSendMessageEmpty(t *testing.T) {
+	c := NewMessageController(usecase.NewMessageUsecase(nil))
+	req := httptest.NewRequest(http.MethodPost, "/api/messages", strings.NewReader(`{"item_id": 1, "receiver_id": 2, "content": ""}`))
+	req = req.WithContext(auth.WithUser(req.Context(), 1, "session"))
+	rec := httptest.NewRecorder()
+	c.sendMessage(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if body := decodeError(t, rec); body.Code != "invalid_message" || body.Details["field"] != "content" {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+// どのエラーにも日本語と英語の文言がある
+func TestErrorMessagesComplete(t *testing.T) {
+	all := []*apperr.Error{
+		errInvalidJSON, errInvalidID, errBodyTooLarge, errLoginRequired, errBadAuthorization,
+		errImageRequired, errAIFailed, errTimeout, errNotFound, errMethodNotAllowed, errInternal,
+		auth.ErrTokenExpired, auth.ErrTokenInvalid, auth.ErrJWKSUnavailable, media.ErrUnsupportedImage,
+		usecase.ErrUnauthenticated, usecase.ErrSessionExpired, usecase.ErrInvalidCredentials,
+		usecase.ErrUserNotFound, usecase.ErrInvalidUser, usecase.ErrEmailInUse, usecase.ErrIdentityInUse,
+		usecase.ErrItemNotFound, usecase.ErrInvalidItem, usecase.ErrInvalidQuery, usecase.ErrInvalidCursor,
+		usecase.ErrNotItemSeller, usecase.ErrItemNotEditable, usecase.ErrInvalidTransition, usecase.ErrTransitionForbidden,
+		usecase.ErrInvalidPurchase, usecase.ErrCannotBuyOwnItem,
+		usecase.ErrCategoryNotFound, usecase.ErrInvalidCategory, usecase.ErrCategoryInUse, usecase.ErrAdminOnly,
+		usecase.ErrImageNotFound, usecase.ErrUnknownImageSize, usecase.ErrImageTooLarge,
+		usecase.ErrInvalidMessage,
+	}
+	for _, e := range all {
+		m, ok := errorMessages[e.Code]
+		if !ok || m.ja == "" || m.en == "" {
+			t.Errorf("%s の文言がない", e.Code)
+		}
+	}
+}
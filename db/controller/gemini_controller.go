@@ -2,63 +2,168 @@ package controller
 
 import (
 	"context"
+	"db/config"
+	"db/metrics"
+	"db/tracing"
+	"db/usecase"
 	"encoding/base64" // 👈 画像デコード用に必須
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/vertexai/genai"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2/google"
 )
 
-const (
-	GeminiProjectID = "term8-naoto-takaku"
-	GeminiLocation  = "asia-northeast1"
-	GeminiModel     = "gemini-2.5-flash"
-)
+type GeminiController struct {
+	Images *usecase.ImageUsecase
+	Config config.Gemini // プロジェクト・リージョン・モデル
+
+	// Gemini のクライアントは最初に使うときに作って使い回す (終了時に Close)
+	mu     sync.Mutex
+	client *genai.Client
+	creds  *google.Credentials // CheckCredentials 用 (トークンはキャッシュされる)
+}
 
-type GeminiController struct{}
+func NewGeminiController(images *usecase.ImageUsecase, cfg config.Gemini) *GeminiController {
+	return &GeminiController{Images: images, Config: cfg}
+}
+
+// genaiClient: 共有のクライアントを返す (まだなければ作る)
+func (c *GeminiController) genaiClient() (*genai.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		// リクエストより長生きするので、リクエストの context は渡さない
+		client, err := genai.NewClient(context.Background(), c.Config.ProjectID, c.Config.Location)
+		if err != nil {
+			return nil, fmt.Errorf("client creation failed: %w", err)
+		}
+		c.client = client
+	}
+	return c.client, nil
+}
 
-func NewGeminiController() *GeminiController {
-	return &GeminiController{}
+// Close: サーバー終了時に呼ぶ (処理中のリクエストが終わってから)
+func (c *GeminiController) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// CheckCredentials: Vertex AI を呼ぶための Google の認証情報とトークンが取れるか (/readyz の任意チェック)
+func (c *GeminiController) CheckCredentials(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.creds == nil {
+		creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
+		if err != nil {
+			return err
+		}
+		c.creds = creds
+	}
+	_, err := c.creds.TokenSource.Token()
+	return err
+}
+
+// RegisterRoutes: Gemini で説明文を作る・価格を査定する API
+func (c *GeminiController) RegisterRoutes(rt *Router) {
+	// 古いフロントは画像を data URL (base64) で送ってくるので、ボディの上限は画像より大きめにする
+	// 生成には時間がかかるのでタイムアウトも長め
+	opts := []RouteOption{BodyLimit(2 * usecase.MaxImageBytes), Timeout(2 * time.Minute)}
+	rt.Handle("POST /api/generate-description", c.handleGenerateDescription, opts...)
+	rt.Handle("POST /api/estimate-price", c.handleEstimatePrice, opts...)
 }
 
 // フロントエンドから受け取るデータ
+// 画像は POST /api/images で上げた image_id を使う (item_image の data URL は古いフロント用)
 type GenerateReq struct {
 	ItemName  string `json:"item_name"`
+	ImageID   int    `json:"image_id"`
 	ItemImage string `json:"item_image"`
 }
 
+// geminiImage: Gemini に渡す画像
+type geminiImage struct {
+	Format string // "jpeg" / "png"
+	Data   []byte
+}
+
+// loadImage: リクエストの画像を読む。画像がなければ nil
+// 遅いときに画像の読み込み (base64 のデコード) なのか Gemini なのか分かるように、スパンを分けておく
+func (c *GeminiController) loadImage(ctx context.Context, req GenerateReq) (*geminiImage, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GeminiController.loadImage")
+	defer span.End()
+
+	if req.ImageID != 0 {
+		span.SetAttributes(attribute.String("image.source", "image_id"), attribute.Int("image.id", req.ImageID))
+		data, img, err := c.Images.ReadAll(ctx, req.ImageID)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+		span.SetAttributes(attribute.Int("image.bytes", len(data)))
+		return &geminiImage{Format: strings.TrimPrefix(img.ContentType, "image/"), Data: data}, nil
+	}
+
+	if req.ItemImage == "" {
+		span.SetAttributes(attribute.String("image.source", "none"))
+		return nil, nil
+	}
+	span.SetAttributes(attribute.String("image.source", "data_url"), attribute.Int("image.data_url_bytes", len(req.ItemImage)))
+	// "data:image/jpeg;base64,......" から "......" の部分だけを取り出す
+	parts := strings.Split(req.ItemImage, ",")
+	if len(parts) != 2 {
+		return nil, nil
+	}
+	// Base64文字列をバイト列に変換
+	decodedData, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		slog.WarnContext(ctx, "item image is not valid base64", "error", err)
+		span.AddEvent("invalid base64")
+		return nil, nil
+	}
+	span.SetAttributes(attribute.Int("image.bytes", len(decodedData)))
+	// ※拡張子は便宜上 jpeg にしていますが、pngでもGeminiは読んでくれます
+	return &geminiImage{Format: "jpeg", Data: decodedData}, nil
+}
+
 // フロントエンドに返すデータ
 type GenerateRes struct {
 	Description string `json:"description"`
 }
 
-func (c *GeminiController) HandleGenerateDescription(w http.ResponseWriter, r *http.Request) {
-	// CORS設定
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+func (c *GeminiController) handleGenerateDescription(w http.ResponseWriter, r *http.Request) {
+	// 1. リクエストを受け取る
+	var req GenerateReq
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// 1. リクエストを受け取る
-	var req GenerateReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	image, err := c.loadImage(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// 2. Geminiで文章を生成する（画像も渡す！）
-	// ▼▼▼ ここを修正しました（引数を2つ渡す） ▼▼▼
-	description, err := generateDescription(req.ItemName, req.ItemImage)
+	description, err := c.generateDescription(r.Context(), req.ItemName, image)
 
 	if err != nil {
-		fmt.Printf("Gemini Error: %v\n", err)
-		http.Error(w, "AI generation failed", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "gemini generate description failed", "model", c.Config.Model, "error", err)
+		writeError(w, r, aiError(err))
 		return
 	}
 
@@ -68,19 +173,42 @@ func (c *GeminiController) HandleGenerateDescription(w http.ResponseWriter, r *h
 	json.NewEncoder(w).Encode(res)
 }
 
-// 実際にGeminiを呼び出す関数
-func generateDescription(itemName, itemImage string) (string, error) {
-	ctx := context.Background()
+// generate: GenerateContent を呼んで、かかった時間・失敗・トークン数をメトリクスとスパンに記録する
+func generate(ctx context.Context, endpoint string, model *genai.GenerativeModel, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "gemini "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.GenAIProviderNameGCPVertexAI,
+			semconv.GenAIRequestModel(model.Name()),
+			attribute.String("ai.endpoint", endpoint),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	resp, err := model.GenerateContent(ctx, parts...)
+	metrics.ObserveAI("gemini", endpoint, start, err)
+	tracing.RecordError(span, err)
+	if resp != nil && resp.UsageMetadata != nil {
+		metrics.AITokens.WithLabelValues("gemini", endpoint, "prompt").Add(float64(resp.UsageMetadata.PromptTokenCount))
+		metrics.AITokens.WithLabelValues("gemini", endpoint, "output").Add(float64(resp.UsageMetadata.CandidatesTokenCount))
+		span.SetAttributes(
+			semconv.GenAIUsageInputTokens(int(resp.UsageMetadata.PromptTokenCount)),
+			semconv.GenAIUsageOutputTokens(int(resp.UsageMetadata.CandidatesTokenCount)),
+		)
+	}
+	return resp, err
+}
 
-	// クライアント作成
-	client, err := genai.NewClient(ctx, GeminiProjectID, GeminiLocation)
+// 実際にGeminiを呼び出す関数
+func (c *GeminiController) generateDescription(ctx context.Context, itemName string, image *geminiImage) (string, error) {
+	client, err := c.genaiClient()
 	if err != nil {
-		return "", fmt.Errorf("client creation failed: %w", err)
+		return "", err
 	}
-	defer client.Close()
 
 	// モデルを選択
-	model := client.GenerativeModel(GeminiModel)
+	model := client.GenerativeModel(c.Config.Model)
 	model.SetTemperature(0.7)
 
 	// ▼▼▼ AIへの入力データを作る（テキスト＋画像） ▼▼▼
@@ -90,28 +218,16 @@ func generateDescription(itemName, itemImage string) (string, error) {
 	prompt := fmt.Sprintf("フリマアプリで「%s」を出品します。購買意欲をそそる魅力的な商品説明文を、200文字以内の日本語で作成してください。挨拶は不要で、いきなり本文から始めてください。", itemName)
 	inputs = append(inputs, genai.Text(prompt))
 
-	// 2. 画像がある場合は、デコードして追加する
-	if itemImage != "" {
-		// "data:image/jpeg;base64,......" から "......" の部分だけを取り出す
-		parts := strings.Split(itemImage, ",")
-		if len(parts) == 2 {
-			// Base64文字列をバイト列に変換
-			decodedData, err := base64.StdEncoding.DecodeString(parts[1])
-			if err == nil {
-				// 成功したら画像データとしてリストに追加
-				// ※拡張子は便宜上 jpeg にしていますが、pngでもGeminiは読んでくれます
-				inputs = append(inputs, genai.ImageData("jpeg", decodedData))
-
-				// 画像用の指示も追加しておく
-				inputs = append(inputs, genai.Text("\nまた、添付した画像の特徴（色、状態、付属品など）も文章に反映してください。"))
-			} else {
-				fmt.Printf("Base64 Decode Error: %v\n", err)
-			}
-		}
+	// 2. 画像がある場合は追加する
+	if image != nil {
+		inputs = append(inputs, genai.ImageData(image.Format, image.Data))
+
+		// 画像用の指示も追加しておく
+		inputs = append(inputs, genai.Text("\nまた、添付した画像の特徴（色、状態、付属品など）も文章に反映してください。"))
 	}
 
 	// 生成実行（inputs... でまとめて渡す）
-	resp, err := model.GenerateContent(ctx, inputs...)
+	resp, err := generate(ctx, "generate_description", model, inputs...)
 	if err != nil {
 		return "", fmt.Errorf("generation failed: %w", err)
 	}
@@ -131,28 +247,23 @@ type EstimateRes struct {
 	Reason string `json:"reason"`
 }
 
-func (c *GeminiController) HandleEstimatePrice(w http.ResponseWriter, r *http.Request) {
-	// CORS設定
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+func (c *GeminiController) handleEstimatePrice(w http.ResponseWriter, r *http.Request) {
+	var req GenerateReq
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	var req GenerateReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	image, err := c.loadImage(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// AIに査定させる
-	price, reason, err := estimatePrice(req.ItemName, req.ItemImage)
+	price, reason, err := c.estimatePrice(r.Context(), req.ItemName, image)
 	if err != nil {
-		fmt.Printf("Estimate Error: %v\n", err)
-		http.Error(w, "AI estimation failed", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "gemini estimate price failed", "model", c.Config.Model, "error", err)
+		writeError(w, r, aiError(err))
 		return
 	}
 
@@ -162,15 +273,13 @@ func (c *GeminiController) HandleEstimatePrice(w http.ResponseWriter, r *http.Re
 }
 
 // ▼▼▼ 追加: Gemini査定ロジック
-func estimatePrice(itemName, itemImage string) (int, string, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, GeminiProjectID, GeminiLocation)
+func (c *GeminiController) estimatePrice(ctx context.Context, itemName string, image *geminiImage) (int, string, error) {
+	client, err := c.genaiClient()
 	if err != nil {
 		return 0, "", err
 	}
-	defer client.Close()
 
-	model := client.GenerativeModel(GeminiModel)
+	model := client.GenerativeModel(c.Config.Model)
 	model.SetTemperature(0.5) // 少し堅実に考えさせる
 
 	// JSONで返事させるためのプロンプト
@@ -186,17 +295,11 @@ func estimatePrice(itemName, itemImage string) (int, string, error) {
 	var inputs []genai.Part
 	inputs = append(inputs, genai.Text(promptText))
 
-	if itemImage != "" {
-		parts := strings.Split(itemImage, ",")
-		if len(parts) == 2 {
-			decodedData, err := base64.StdEncoding.DecodeString(parts[1])
-			if err == nil {
-				inputs = append(inputs, genai.ImageData("jpeg", decodedData))
-			}
-		}
+	if image != nil {
+		inputs = append(inputs, genai.ImageData(image.Format, image.Data))
 	}
 
-	resp, err := model.GenerateContent(ctx, inputs...)
+	resp, err := generate(ctx, "estimate_price", model, inputs...)
 	if err != nil {
 		return 0, "", err
 	}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"db/buildinfo"
+)
+
+// HealthController: ロードバランサーや監視向けの状態確認
+//
+//	GET /healthz : プロセスが動いていれば 200 (依存先は見ない)
+//	GET /readyz  : 依存先 (DB など) をチェックして、リクエストを受けられるなら 200
+//	GET /version : ビルドしたコミットと時刻
+//
+// 終了処理が始まったら SetReady(false) して、新しいリクエストを送らないようにしてもらう
+type HealthController struct {
+	Checks       []HealthCheck
+	CheckTimeout time.Duration // 1つのチェックにかけてよい時間
+
+	ready atomic.Bool
+}
+
+// HealthCheck: /readyz で見る依存先1つ
+// Optional なら失敗しても 503 にはしない (status が "degraded" になるだけ)
+type HealthCheck struct {
+	Name     string
+	Optional bool
+	Check    func(ctx context.Context) error
+}
+
+// checkResult: /readyz のレスポンスのチェック1つ分
+// /readyz は公開しているので、失敗の中身 (DSN のホスト名や SQL など) は返さずログにだけ出す
+type checkResult struct {
+	Status     string `json:"status"` // "ok" / "error"
+	Optional   bool   `json:"optional,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+const defaultCheckTimeout = 2 * time.Second
+
+func NewHealthController(checks ...HealthCheck) *HealthController {
+	return &HealthController{Checks: checks, CheckTimeout: defaultCheckTimeout}
+}
+
+func (c *HealthController) SetReady(ready bool) {
+	c.ready.Store(ready)
+}
+
+func (c *HealthController) RegisterRoutes(rt *Router) {
+	rt.Handle("GET /healthz", c.healthz)
+	rt.Handle("GET /readyz", c.readyz)
+	rt.Handle("GET /version", c.version)
+}
+
+func (c *HealthController) healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz: 起動中・終了処理中、または必須のチェックが失敗したら 503
+func (c *HealthController) readyz(w http.ResponseWriter, r *http.Request) {
+	if !c.ready.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, map[string]string{"status": "not_ready"})
+		return
+	}
+
+	results := c.runChecks(r.Context())
+	status, code := "ready", http.StatusOK
+	for _, res := range results {
+		if res.Status == "ok" {
+			continue
+		}
+		if !res.Optional {
+			status, code = "not_ready", http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+	writeHealth(w, code, map[string]any{"status": status, "checks": results})
+}
+
+// runChecks: チェックを並行して実行する (それぞれ CheckTimeout まで)
+func (c *HealthController) runChecks(ctx context.Context) map[string]checkResult {
+	results := make(map[string]checkResult, len(c.Checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, c.CheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(ctx)
+			res := checkResult{Status: "ok", Optional: check.Optional, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = "error"
+				slog.ErrorContext(ctx, "health check failed", "check", check.Name, "optional", check.Optional, "error", err)
+			}
+			mu.Lock()
+			results[check.Name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (c *HealthController) version(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, buildinfo.Get())
+}
+
+func writeHealth(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
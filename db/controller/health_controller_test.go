@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthController_Readyz(t *testing.T) {
+	health := NewHealthController()
+	rt := NewRouter(nil)
+	rt.Register(health)
+
+	if rec := serve(rt, http.MethodGet, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("起動前: got %d", rec.Code)
+	}
+	health.SetReady(true)
+	if rec := serve(rt, http.MethodGet, "/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("起動後: got %d", rec.Code)
+	}
+	health.SetReady(false)
+	if rec := serve(rt, http.MethodGet, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("終了処理中: got %d", rec.Code)
+	}
+}
+
+func TestHealthController_Checks(t *testing.T) {
+	fail := func(ctx context.Context) error { return errors.New("dial tcp db.internal:3306: connection refused") }
+	ok := func(ctx context.Context) error { return nil }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tests := []struct {
+		name       string
+		checks     []HealthCheck
+		wantCode   int
+		wantStatus string
+	}{
+		{"全部OK", []HealthCheck{{Name: "db", Check: ok}}, http.StatusOK, "ready"},
+		{"必須が失敗", []HealthCheck{{Name: "db", Check: fail}, {Name: "ai", Optional: true, Check: ok}}, http.StatusServiceUnavailable, "not_ready"},
+		{"任意だけ失敗", []HealthCheck{{Name: "db", Check: ok}, {Name: "ai", Optional: true, Check: fail}}, http.StatusOK, "degraded"},
+		{"タイムアウト", []HealthCheck{{Name: "db", Check: slow}}, http.StatusServiceUnavailable, "not_ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewHealthController(tt.checks...)
+			health.CheckTimeout = 10 * time.Millisecond
+			health.SetReady(true)
+			rt := NewRouter(nil)
+			rt.Register(health)
+
+			rec := serve(rt, http.MethodGet, "/readyz")
+			var body struct {
+				Status string                 `json:"status"`
+				Checks map[string]checkResult `json:"checks"`
+			}
+			json.NewDecoder(rec.Body).Decode(&body)
+			if rec.Code != tt.wantCode || body.Status != tt.wantStatus {
+				t.Errorf("got %d %s, want %d %s", rec.Code, body.Status, tt.wantCode, tt.wantStatus)
+			}
+			if len(body.Checks) != len(tt.checks) {
+				t.Errorf("checks = %v", body.Checks)
+			}
+			// 失敗の中身 (ホスト名など) は返さない
+			if strings.Contains(rec.Body.String(), "db.internal") || strings.Contains(rec.Body.String(), "deadline") {
+				t.Errorf("error detail leaked: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestHealthController_HealthzAndVersion(t *testing.T) {
+	rt := NewRouter(nil)
+	rt.Register(NewHealthController(HealthCheck{Name: "db", Check: func(ctx context.Context) error { return errors.New("down") }}))
+
+	// healthz は依存先も readiness も見ない
+	if rec := serve(rt, http.MethodGet, "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("healthz: got %d", rec.Code)
+	}
+	rec := serve(rt, http.MethodGet, "/version")
+	var info map[string]any
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&info) != nil || info["commit"] == "" || info["go_version"] == "" {
+		t.Errorf("version: got %d %v", rec.Code, info)
+	}
+}
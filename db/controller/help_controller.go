@@ -6,27 +6,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"db/config"
+	"db/metrics"
+	"db/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/option"
 	"google.golang.org/api/transport"
 )
 
-// ▼ ここをご自身のIDに書き換えてください
-const (
-	ProjectID = "term8-naoto-takaku"                  // プロジェクトID
-	Location  = "global"                              // ロケーション
-	EngineID  = "hackathon-manual-help_1766104642390" // エンジンID
-)
-
 // ※注意: カリキュラムでは default_config ですが、最近の汎用検索アプリは default_search の場合が多いです。
 // もし 404 エラーが出る場合は、末尾の default_search を default_config に戻してみてください。
 const apiEndpoint = "https://discoveryengine.googleapis.com/v1beta/projects/%s/locations/%s/collections/default_collection/engines/%s/servingConfigs/default_search:search"
 
-type HelpController struct{}
+// HelpController: プロジェクト・ロケーション・エンジンIDは config (HELP_*) で指定する
+type HelpController struct {
+	Config config.Help
+}
 
-func NewHelpController() *HelpController {
-	return &HelpController{}
+func NewHelpController(cfg config.Help) *HelpController {
+	return &HelpController{Config: cfg}
 }
 
 // ▼▼▼ ここから下はカリキュラムの構造体定義 (そのまま) ▼▼▼
@@ -88,30 +93,36 @@ type HelpReq struct {
 	Query string `json:"query"`
 }
 
-// ハンドラー関数（Webサーバー用）
-func (c *HelpController) HandleHelp(w http.ResponseWriter, r *http.Request) {
-	// CORS設定
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
+// RegisterRoutes: ヘルプ検索の API
+func (c *HelpController) RegisterRoutes(rt *Router) {
+	rt.Handle("POST /api/help", c.handleHelp, Timeout(time.Minute))
+}
 
+// ハンドラー関数（Webサーバー用）
+func (c *HelpController) handleHelp(w http.ResponseWriter, r *http.Request) {
 	// 1. フロントエンドから質問を受け取る
 	var req HelpReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	// 2. カリキュラムのロジックで検索実行
-	answer, err := searchSample(ProjectID, Location, EngineID, req.Query)
+	ctx, span := tracing.Tracer().Start(r.Context(), "discovery_engine help_search",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.GenAIProviderNameGCPVertexAI,
+			attribute.String("ai.endpoint", "help_search"),
+			attribute.String("discovery_engine.engine_id", c.Config.EngineID),
+		),
+	)
+	start := time.Now()
+	answer, err := searchSample(ctx, c.Config.ProjectID, c.Config.Location, c.Config.EngineID, req.Query)
+	metrics.ObserveAI("discovery_engine", "help_search", start, err)
+	tracing.RecordError(span, err)
+	span.End()
 	if err != nil {
-		fmt.Printf("Vertex AI Error: %v\n", err)
-		http.Error(w, "AI processing failed", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "help search failed", "engine_id", c.Config.EngineID, "error", err)
+		writeError(w, r, aiError(err))
 		return
 	}
 
@@ -122,7 +133,8 @@ func (c *HelpController) HandleHelp(w http.ResponseWriter, r *http.Request) {
 }
 
 // カリキュラムの searchSample を少し改造（文字列を返すように変更）
-func searchSample(projectID, location, engineID, searchQuery string) (string, error) {
+// ctx が切れたら (クライアントの切断・ルートのタイムアウト) 検索も打ち切る
+func searchSample(ctx context.Context, projectID, location, engineID, searchQuery string) (string, error) {
 	url := fmt.Sprintf(apiEndpoint, projectID, location, engineID)
 
 	requestBody := SearchRequest{
@@ -156,31 +168,30 @@ func searchSample(projectID, location, engineID, searchQuery string) (string, er
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	ctx := context.Background()
 	// カリキュラム通り transport を使用
 	client, _, err := transport.NewHTTPClient(ctx, option.WithScopes("https://www.googleapis.com/auth/cloud-platform"))
 	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP client: %v", err)
+		return "", fmt.Errorf("failed to create HTTP client: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
+		return "", fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -190,7 +201,7 @@ func searchSample(projectID, location, engineID, searchQuery string) (string, er
 	// ★追加部分: JSONから回答だけを取り出す処理
 	var searchResp SearchResponse
 	if err := json.Unmarshal(body, &searchResp); err != nil {
-		return "", fmt.Errorf("JSON parse failed: %v", err)
+		return "", fmt.Errorf("JSON parse failed: %w", err)
 	}
 
 	if searchResp.Summary.SummaryText == "" {
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"db/model"
+	"db/usecase"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type ImageController struct {
+	Usecase *usecase.ImageUsecase
+}
+
+func NewImageController(u *usecase.ImageUsecase) *ImageController {
+	return &ImageController{Usecase: u}
+}
+
+// RegisterRoutes: 画像のアップロードと配信
+func (c *ImageController) RegisterRoutes(rt *Router) {
+	// フォームの他の部分の分だけ少し余裕を持たせる
+	rt.HandleAuth("POST /api/images", c.upload, BodyLimit(usecase.MaxImageBytes+1<<20))
+	rt.Handle("GET /api/images/{id}", c.serveImage)
+}
+
+// upload: multipart/form-data の "image" フィールドで1枚アップロードする
+func (c *ImageController) upload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	file, _, err := r.FormFile("image")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, usecase.ErrImageTooLarge)
+		return
+	}
+	if err != nil {
+		writeError(w, r, errImageRequired)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, usecase.MaxImageBytes+1))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 大きすぎれば 413、JPEG・PNG・WebP 以外なら 415
+	img, err := c.Usecase.Upload(r.Context(), userID, data)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(img)
+}
+
+// serveImage: ?size=thumb|medium|full (省略時は full)
+// 画像の中身は変わらないので、長くキャッシュさせる (ETag は保存先のキー = 中身のハッシュ)
+func (c *ImageController) serveImage(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "image")
+	if !ok {
+		return
+	}
+	size := model.ImageSize(r.URL.Query().Get("size"))
+	if size == "" {
+		size = model.ImageSizeFull
+	}
+	acceptWebP := strings.Contains(r.Header.Get("Accept"), "image/webp")
+
+	variant, err := c.Usecase.Variant(r.Context(), id, size, acceptWebP)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	etag := `"` + path.Base(variant.StorageKey) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Vary", "Accept") // WebP を返すかは Accept で変わる
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err := c.Usecase.Open(r.Context(), variant.StorageKey)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", variant.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(variant.Bytes))
+	io.Copy(w, body)
+}
@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"db/auth"
+	"db/model"
 	"db/usecase"
 	"encoding/json"
 	"net/http"
+	"strconv"
 )
 
 type ItemController struct {
@@ -14,41 +17,158 @@ func NewItemController(u *usecase.ItemUsecase) *ItemController {
 	return &ItemController{Usecase: u}
 }
 
-func (c *ItemController) Handler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// RegisterRoutes: 商品の API (どれもトークンがあれば呼び出し元を見る)
+func (c *ItemController) RegisterRoutes(rt *Router) {
+	rt.HandleAuth("GET /api/items", c.listItems)
+	rt.HandleAuth("POST /api/items", c.createItem)
+	rt.HandleAuth("GET /api/items/{id}", c.getItemDetail)
+	rt.HandleAuth("PATCH /api/items/{id}", c.updateItem)
+	rt.HandleAuth("DELETE /api/items/{id}", c.withdrawItem)
+	rt.HandleAuth("POST /api/items/{id}/status", c.changeStatus)
+}
+
+// listItems: 一覧取得
+// ?category_id=&status=&min_price=&max_price=&seller_id=&sort=&cursor=&limit=
+// ?q= があればキーワード検索 (?q=&limit=&offset=)
+func (c *ItemController) listItems(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Has("q") {
+		c.searchItems(w, r)
+		return
+	}
+
+	req := usecase.ListItemsReq{
+		Status: model.ItemStatus(q.Get("status")),
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+	}
+	req.CategoryID, _ = strconv.Atoi(q.Get("category_id"))
+	req.MinPrice, _ = strconv.Atoi(q.Get("min_price"))
+	req.MaxPrice, _ = strconv.Atoi(q.Get("max_price"))
+	req.SellerID, _ = strconv.Atoi(q.Get("seller_id"))
+	req.Limit, _ = strconv.Atoi(q.Get("limit"))
+	req.ViewerID, _ = auth.UserIDFromContext(r.Context())
+
+	page, err := c.Usecase.GetItems(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// createItem: 商品出品 (出品者はログイン中のユーザー)
+func (c *ItemController) createItem(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	var req usecase.CreateItemReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.SellerID = sellerID
+
+	id, err := c.Usecase.CreateItem(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
+}
+
+func (c *ItemController) getItemDetail(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "item")
+	if !ok {
+		return
+	}
+	viewerID, _ := auth.UserIDFromContext(r.Context())
+	detail, err := c.Usecase.GetItemDetail(r.Context(), id, viewerID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+func (c *ItemController) updateItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "item")
+	if !ok {
+		return
+	}
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	var req usecase.UpdateItemReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
 
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+	if err := c.Usecase.UpdateItem(r.Context(), userID, id, req); err != nil {
+		writeError(w, r, err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+}
 
-	switch r.Method {
-	case http.MethodGet:
-		// 一覧取得
-		items, err := c.Usecase.GetItems()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(items)
+func (c *ItemController) withdrawItem(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "item")
+	if !ok {
+		return
+	}
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
 
-	case http.MethodPost:
-		// 商品出品
-		var req usecase.CreateItemReq
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
-			return
-		}
+	if err := c.Usecase.WithdrawItem(r.Context(), userID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "withdrawn"})
+}
 
-		id, err := c.Usecase.CreateItem(req)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]int{"id": id})
+func (c *ItemController) changeStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "item")
+	if !ok {
+		return
+	}
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Status model.ItemStatus `json:"status"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := c.Usecase.ChangeStatus(r.Context(), userID, id, req.Status); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": string(req.Status)})
+}
+
+func (c *ItemController) searchItems(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := usecase.SearchItemsReq{Query: q.Get("q")}
+	req.Limit, _ = strconv.Atoi(q.Get("limit"))
+	req.Offset, _ = strconv.Atoi(q.Get("offset"))
+
+	page, err := c.Usecase.SearchItems(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
 }
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"db/model"
+	"db/usecase"
+)
+
+// stubItemRepo: 商品詳細で使うメソッドだけ (ほかを呼ぶと nil の埋め込みで panic する)
+type stubItemRepo struct {
+	usecase.ItemRepository
+	items map[int]model.Item
+}
+
+func (s *stubItemRepo) FindByID(ctx context.Context, id int) (*model.Item, error) {
+	if item, ok := s.items[id]; ok {
+		return &item, nil
+	}
+	return nil, nil
+}
+func (s *stubItemRepo) FindSellerSummary(ctx context.Context, sellerID int) (*model.SellerSummary, error) {
+	return &model.SellerSummary{ID: sellerID, DisplayName: "出品者"}, nil
+}
+func (s *stubItemRepo) FindRelated(ctx context.Context, categoryID, excludeID, limit int) ([]model.Item, error) {
+	return nil, nil
+}
+func (s *stubItemRepo) GetPriceHistory(ctx context.Context, itemID int) ([]model.PriceChange, error) {
+	return nil, nil
+}
+func (s *stubItemRepo) GetStatusHistory(ctx context.Context, itemID int) ([]model.ItemStatusChange, error) {
+	return nil, nil
+}
+
+type stubImageRepo struct {
+	usecase.ImageRepository
+}
+
+func (s *stubImageRepo) FindByItem(ctx context.Context, itemID int) ([]model.Image, error) {
+	return []model.Image{{ID: 7, OwnerID: 1}}, nil
+}
+
+func newItemTestRouter() *Router {
+	repo := &stubItemRepo{items: map[int]model.Item{
+		1: {ID: 1, SellerID: 1, Name: "スマホケース", Status: model.ItemStatusOnSale},
+		2: {ID: 2, SellerID: 1, Name: "下書き", Status: model.ItemStatusDraft},
+	}}
+	rt := NewRouter(NewAuthMiddleware(nil))
+	NewItemController(usecase.NewItemUsecase(repo, nil, &stubImageRepo{}, nil)).RegisterRoutes(rt)
+	return rt
+}
+
+func TestItemController_GetItemDetail(t *testing.T) {
+	rec := serve(newItemTestRouter(), http.MethodGet, "/api/items/1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	var detail struct {
+		ID     int                 `json:"id"`
+		Status model.ItemStatus    `json:"status"`
+		Seller model.SellerSummary `json:"seller"`
+		Images []model.Image       `json:"images"`
+		// 出品者本人以外には出さない
+		StatusHistory []model.ItemStatusChange `json:"status_history"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.ID != 1 || detail.Status != model.ItemStatusOnSale || detail.Seller.ID != 1 || detail.Seller.DisplayName != "出品者" {
+		t.Errorf("detail = %+v", detail)
+	}
+	if len(detail.Images) != 1 || detail.Images[0].ID != 7 {
+		t.Errorf("images = %+v", detail.Images)
+	}
+	if detail.StatusHistory != nil {
+		t.Errorf("status history shown to a visitor: %+v", detail.StatusHistory)
+	}
+}
+
+func TestItemController_GetItemDetailNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"存在しない商品", "/api/items/99"},
+		{"下書きは出品者以外には見えない", "/api/items/2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(newItemTestRouter(), http.MethodGet, tt.path)
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("got %d", rec.Code)
+			}
+			var body errorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != "item_not_found" {
+				t.Errorf("body = %+v, %v", body, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"db/auth"
+	"db/metrics"
+)
+
+// MetricsController: Prometheus のスクレイプ用 (GET /metrics)
+// Token を設定すると Authorization: Bearer <Token> がないと 401 (公開した URL から中身を見られないように)
+type MetricsController struct {
+	Token   string
+	handler http.Handler
+}
+
+func NewMetricsController(token string) *MetricsController {
+	return &MetricsController{Token: token, handler: metrics.Handler()}
+}
+
+func (c *MetricsController) RegisterRoutes(rt *Router) {
+	rt.Handle("GET /metrics", c.serveMetrics)
+}
+
+func (c *MetricsController) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if c.Token != "" {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, []byte("Bearer "+c.Token)) != 1 {
+			writeError(w, r, auth.ErrTokenInvalid)
+			return
+		}
+	}
+	c.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"db/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	rt := NewRouter(nil)
+	rt.Use(RequestID, Metrics)
+	rt.Handle("GET /api/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	counter := metrics.HTTPRequests.WithLabelValues("GET", "GET /api/things/{id}", "418")
+	unmatched := metrics.HTTPRequests.WithLabelValues("GET", "unmatched", "404")
+	before, beforeUnmatched := testutil.ToFloat64(counter), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/api/things/1", "/api/things/2", "/nope"} {
+		rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	// ID が違ってもルートは1つ
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Errorf("route count = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - beforeUnmatched; got != 1 {
+		t.Errorf("unmatched count = %v, want 1", got)
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	rt := NewRouter(nil)
+	rt.Register(NewMetricsController("s3cret"))
+
+	tests := []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.auth, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusOK && !strings.Contains(rec.Body.String(), "http_requests_in_flight") {
+			t.Error("metrics body is missing http_requests_in_flight")
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"time"
+
+	"db/logging"
+	"db/metrics"
+	"db/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware: ハンドラーの前後に共通の処理を挟む
+type Middleware func(http.Handler) http.Handler
+
+// Chain: mws[0] が一番外側になるように重ねる
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// CORSConfig: どのオリジンからのブラウザのアクセスを許すか
+type CORSConfig struct {
+	AllowedOrigins   []string // "*" なら全部。空なら CORS ヘッダーを付けない
+	AllowCredentials bool     // Cookie などの資格情報付きリクエストを許す
+	MaxAge           time.Duration
+}
+
+// CORS: 許可したオリジンにだけ Access-Control-* を付ける
+// プリフライトの Allow-Methods は Router がパスごとに付ける
+func CORS(cfg CORSConfig) Middleware {
+	allowAll := slices.Contains(cfg.AllowedOrigins, "*")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			if origin != "" && (allowAll || slices.Contains(cfg.AllowedOrigins, origin)) {
+				// 資格情報付きのときは "*" が使えないので、オリジンをそのまま返す
+				if allowAll && !cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+				if cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID") // 問い合わせのときにフロントから伝えてもらう
+				if r.Method == http.MethodOptions {
+					// X-Request-ID はフロントが付けて送ってくることがある (RequestID がそのまま使う)
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+					if cfg.MaxAge > 0 {
+						w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestID: X-Request-ID があればそれを、なければ新しく作ったIDを context とレスポンスヘッダーに入れる
+// 以降 slog.*Context(ctx, ...) で出したログには、このIDが付く (logging パッケージ)
+// 一番外側に置くこと
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set("X-Request-ID", id)
+		req := &logging.Request{ID: id, Start: time.Now()}
+		next.ServeHTTP(w, r.WithContext(logging.WithRequest(r.Context(), req)))
+	})
+}
+
+// validRequestID: 呼び出し元のIDはログにそのまま出るので、短い英数字と記号だけ受け付ける
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Recover: ハンドラーの panic を拾ってログに残し、500 (エラーの JSON) を返す
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err) // クライアントが切断しただけ (net/http に任せる)
+			}
+			slog.ErrorContext(r.Context(), "panic", "method", r.Method, "path", r.URL.Path, "error", err, "stack", string(debug.Stack()))
+			writeErrorStatus(w, r, http.StatusInternalServerError, errInternal)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessLog: 1リクエスト1行でメソッド・パス・ステータスを出す
+// リクエストID・ルート・ユーザー・処理時間は logging のハンドラーが付ける (RequestID より内側に置く)
+// 5xx は ERROR、4xx は WARN
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case rec.status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+		)
+	})
+}
+
+// Metrics: リクエスト数と処理時間をルート・ステータスごとに数える (/metrics)
+// ルートは logging.Request から取るので RequestID より内側に置く。Recover より外側なら panic も 500 として数える
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.HTTPInFlight.Inc()
+		defer metrics.HTTPInFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if req := logging.RequestFrom(r.Context()); req != nil && req.Route != "" {
+			route = req.Route
+		}
+		status := strconv.Itoa(rec.status)
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Trace: リクエスト1つを1つのサーバースパンにする (OpenTelemetry)
+// 呼び出し元の traceparent があればその続きにする。スパン名はルート ("GET /api/items/{id}") で、
+// ルートは処理の後でないと分からないので最後に付け直す
+// RequestID より内側、AccessLog より外側に置く (アクセスログにも trace_id が付く)
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request_id", logging.RequestID(ctx)),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if req := logging.RequestFrom(ctx); req != nil {
+			if req.Route != "" {
+				span.SetName(req.Route)
+				span.SetAttributes(semconv.HTTPRoute(req.Route))
+			}
+			if req.UserID != 0 {
+				span.SetAttributes(attribute.Int("user.id", req.UserID))
+			}
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RouteOption: ルートごとの設定 (Router.Handle の最後に渡す)
+type RouteOption func(*route)
+
+type route struct {
+	bodyLimit int64
+	timeout   time.Duration
+}
+
+// BodyLimit: リクエストボディの上限 (デフォルトは Router.DefaultBodyLimit)
+func BodyLimit(n int64) RouteOption {
+	return func(rt *route) { rt.bodyLimit = n }
+}
+
+// Timeout: リクエストの context の期限 (デフォルトは Router.DefaultTimeout)
+// 期限が来ると DB や外部 API の呼び出しが context 経由で打ち切られる
+func Timeout(d time.Duration) RouteOption {
+	return func(rt *route) { rt.timeout = d }
+}
+
+// limit: ボディの上限と context の期限を付けてから h を呼ぶ
+func (o route) limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.bodyLimit > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, o.bodyLimit)
+		}
+		if o.timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), o.timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		h(w, r)
+	}
+}
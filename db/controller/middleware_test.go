@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"db/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name      string
+		cfg       CORSConfig
+		origin    string
+		wantAllow string
+		wantCreds string
+	}{
+		{"全部許可", CORSConfig{AllowedOrigins: []string{"*"}}, "https://a.example", "*", ""},
+		{"許可リストに入っている", CORSConfig{AllowedOrigins: []string{"https://a.example"}}, "https://a.example", "https://a.example", ""},
+		{"許可リストにない", CORSConfig{AllowedOrigins: []string{"https://a.example"}}, "https://evil.example", "", ""},
+		{"資格情報付きは * を使わない", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "https://a.example", "https://a.example", "true"},
+		{"Origin なし", CORSConfig{AllowedOrigins: []string{"*"}}, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			CORS(tt.cfg)(ok).ServeHTTP(rec, req)
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCreds {
+				t.Errorf("Allow-Credentials = %q, want %q", got, tt.wantCreds)
+			}
+		})
+	}
+}
+
+// プリフライト: フロントが X-Request-ID を付けて送れて、レスポンスの X-Request-ID も読める
+func TestCORS_Preflight(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://a.example")
+	req.Header.Set("Access-Control-Request-Headers", "x-request-id")
+	rec := httptest.NewRecorder()
+	CORS(CORSConfig{AllowedOrigins: []string{"https://a.example"}})(http.NotFoundHandler()).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-Request-ID") {
+		t.Errorf("Allow-Headers = %q, want X-Request-ID", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(got, "X-Request-ID") {
+		t.Errorf("Expose-Headers = %q, want X-Request-ID", got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var body errorResponse
+	if rec.Code != http.StatusInternalServerError || json.NewDecoder(rec.Body).Decode(&body) != nil || body.Code != "internal_error" {
+		t.Errorf("got %d %v", rec.Code, body)
+	}
+}
+
+func TestRouteLimits(t *testing.T) {
+	rt := NewRouter(nil)
+	rt.DefaultBodyLimit = 10
+	read := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if _, ok := r.Context().Deadline(); !ok {
+			http.Error(w, "no deadline", http.StatusInternalServerError)
+		}
+	}
+	rt.Handle("POST /small", read)
+	rt.Handle("POST /large", read, BodyLimit(100), Timeout(time.Second))
+
+	body := strings.Repeat("x", 50)
+	for path, want := range map[string]int{"/small": http.StatusRequestEntityTooLarge, "/large": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if rec.Code != want {
+			t.Errorf("%s: got %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var gotID string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = logging.RequestID(r.Context())
+	}))
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"ヘッダーのIDを使う", "abc-123_x.y:z", true},
+		{"なければ作る", "", false},
+		{"変な文字が入っていたら作り直す", "abc\ninjected", false},
+		{"長すぎたら作り直す", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			header := rec.Header().Get("X-Request-ID")
+			if header == "" || header != gotID {
+				t.Fatalf("header = %q, context = %q", header, gotID)
+			}
+			if (header == tt.incoming) != tt.keep {
+				t.Errorf("id = %q, incoming = %q", header, tt.incoming)
+			}
+		})
+	}
+}
+
+// アクセスログにリクエストID・ルート・ステータスが出る
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "info", "json")
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(prev)
+
+	rt := NewRouter(nil)
+	rt.Use(RequestID, AccessLog)
+	rt.Handle("GET /api/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusNotFound)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/items/3", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rt.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	want := map[string]any{
+		"msg":        "request",
+		"level":      "WARN",
+		"request_id": "req-42",
+		"route":      "GET /api/items/{id}",
+		"path":       "/api/items/3",
+		"status":     float64(http.StatusNotFound),
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+}
+
+// リクエストがルート名のスパンになり、呼び出し元の traceparent の続きになる
+func TestTrace(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	}()
+
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "info", "json")
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(prev)
+
+	rt := NewRouter(nil)
+	rt.Use(RequestID, Trace, AccessLog)
+	rt.Handle("POST /api/items/{id}/purchase", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/items/3/purchase", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-ID", "req-7")
+	rt.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("spans = %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "POST /api/items/{id}/purchase" {
+		t.Errorf("name = %q", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s (traceparent の続きになっていない)", got)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("status = %v, want Error", span.Status())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["http.response.status_code"].AsInt64() != 500 || attrs["request_id"].AsString() != "req-7" {
+		t.Errorf("attributes = %v", span.Attributes())
+	}
+
+	// アクセスログから同じトレースをたどれる
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if line["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace_id = %v", line["trace_id"])
+	}
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"db/logging"
+)
+
+// Router: "GET /api/items/{id}" のようなメソッド + パスのパターンでハンドラーを振り分ける
+// 中身は標準の http.ServeMux (パスパラメータは r.PathValue で取る)
+//   - パスはあるがメソッドが違う → 405 (Allow ヘッダー付き)
+//   - パスがない → 404
+//
+// どちらも本文はエラーの JSON (errors.go) で返す
+// Use で登録したミドルウェア (CORS・panic の回復など) は全リクエストに1回ずつかかる
+type Router struct {
+	mux  *http.ServeMux
+	auth *AuthMiddleware
+
+	// ルートごとに BodyLimit / Timeout を指定しなかったときの値 (0 なら制限なし)
+	DefaultBodyLimit int64
+	DefaultTimeout   time.Duration
+
+	// パス → 登録済みのメソッド (CORS のプリフライトに返す)
+	methods     map[string][]string
+	middlewares []Middleware
+	handler     http.Handler
+}
+
+// RouteRegistrar: 自分の API を Router に登録するコントローラー
+type RouteRegistrar interface {
+	RegisterRoutes(rt *Router)
+}
+
+const (
+	defaultBodyLimit = 1 << 20 // JSON の API ならこれで十分
+	defaultTimeout   = 30 * time.Second
+)
+
+func NewRouter(auth *AuthMiddleware) *Router {
+	rt := &Router{
+		mux:              http.NewServeMux(),
+		auth:             auth,
+		DefaultBodyLimit: defaultBodyLimit,
+		DefaultTimeout:   defaultTimeout,
+		methods:          map[string][]string{},
+	}
+	rt.handler = http.HandlerFunc(rt.dispatch)
+	return rt
+}
+
+// Use: ミドルウェアを追加する (先に追加したものが外側)
+func (rt *Router) Use(mws ...Middleware) {
+	rt.middlewares = append(rt.middlewares, mws...)
+	rt.handler = Chain(http.HandlerFunc(rt.dispatch), rt.middlewares...)
+}
+
+// Register: コントローラーのルートをまとめて登録する
+func (rt *Router) Register(controllers ...RouteRegistrar) {
+	for _, c := range controllers {
+		c.RegisterRoutes(rt)
+	}
+}
+
+// Handle: pattern は "METHOD /path" の形 (メソッドは必須)
+func (rt *Router) Handle(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || method == "" || !strings.HasPrefix(path, "/") {
+		panic("router: pattern must be \"METHOD /path\": " + pattern)
+	}
+	o := route{bodyLimit: rt.DefaultBodyLimit, timeout: rt.DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	h = o.limit(h)
+	rt.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		logging.SetRoute(r.Context(), pattern)
+		h(w, r)
+	})
+
+	// パスごとにプリフライト(OPTIONS)の応答を1つ用意する
+	if _, ok := rt.methods[path]; !ok {
+		rt.mux.HandleFunc("OPTIONS "+path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(rt.methods[path], ", ")+", "+http.MethodOptions)
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	rt.methods[path] = append(rt.methods[path], method)
+	sort.Strings(rt.methods[path])
+}
+
+// HandleAuth: Bearer トークンがあれば検証して呼び出し元を context に入れてから h を呼ぶ
+// ログイン必須かどうかは h の中で requireUser を使って判定する
+func (rt *Router) HandleAuth(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	rt.Handle(pattern, rt.auth.Wrap(h), opts...)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.handler.ServeHTTP(w, r)
+}
+
+func (rt *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	// どのパターンにも当たらなかった (404 か 405) ときは、ServeMux の本文を JSON に差し替える
+	// (パスパラメータは mux.ServeHTTP でしか r に入らないので、振り分け自体は mux に任せる)
+	if _, pattern := rt.mux.Handler(r); pattern == "" {
+		w = &jsonErrorWriter{ResponseWriter: w, r: r}
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// jsonErrorWriter: WriteHeader されたステータスに合わせてエラーの JSON を書き、元の本文は捨てる
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	r     *http.Request
+	wrote bool
+}
+
+func (w *jsonErrorWriter) WriteHeader(code int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+	e := errNotFound
+	if code == http.StatusMethodNotAllowed {
+		e = errMethodNotAllowed
+	}
+	writeErrorStatus(w.ResponseWriter, w.r, code, e)
+}
+
+func (w *jsonErrorWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	return len(b), nil
+}
+
+// pathID: パスパラメータ {id} を正の整数として読む。不正なら 400 を書き込んで false を返す
+// what はエラーの details 用 ("item" なら {"field": "item_id"})
+func pathID(w http.ResponseWriter, r *http.Request, what string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeError(w, r, errInvalidID.With("field", what+"_id"))
+		return 0, false
+	}
+	return id, true
+}
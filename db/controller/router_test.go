@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRouter() *Router {
+	rt := NewRouter(nil)
+	rt.Handle("GET /api/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r, "thing")
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"id": id})
+	})
+	rt.Handle("DELETE /api/things/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	return rt
+}
+
+func serve(rt *Router, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestRouter_PathParam(t *testing.T) {
+	rec := serve(newTestRouter(), http.MethodGet, "/api/things/42")
+	var body map[string]int
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusOK || body["id"] != 42 {
+		t.Errorf("got %d %v", rec.Code, body)
+	}
+
+	rec = serve(newTestRouter(), http.MethodGet, "/api/things/abc")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("不正なIDで %d", rec.Code)
+	}
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	rec := serve(newTestRouter(), http.MethodPost, "/api/things/1")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow == "" {
+		t.Error("Allow ヘッダーがない")
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != "method_not_allowed" {
+		t.Errorf("本文が JSON でない: %v %v", body, err)
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	rec := serve(newTestRouter(), http.MethodGet, "/api/nothing")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("got %d", rec.Code)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != "not_found" {
+		t.Errorf("本文が JSON でない: %v %v", body, err)
+	}
+}
+
+func TestRouter_Preflight(t *testing.T) {
+	rec := serve(newTestRouter(), http.MethodOptions, "/api/things/1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "DELETE, GET, OPTIONS" {
+		t.Errorf("Access-Control-Allow-Methods = %q", got)
+	}
+}
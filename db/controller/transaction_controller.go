@@ -14,30 +14,30 @@ func NewTransactionController(u *usecase.TransactionUsecase) *TransactionControl
 	return &TransactionController{Usecase: u}
 }
 
-func (c *TransactionController) Handler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// RegisterRoutes: 購入の API
+func (c *TransactionController) RegisterRoutes(rt *Router) {
+	rt.HandleAuth("POST /api/purchase", c.purchase)
+}
 
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+func (c *TransactionController) purchase(w http.ResponseWriter, r *http.Request) {
+	// 購入者はログイン中のユーザー
+	buyerID, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
+	var req usecase.PurchaseReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.BuyerID = buyerID
 
-	if r.Method == http.MethodPost {
-		var req usecase.PurchaseReq
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
-			return
-		}
-
-		if err := c.Usecase.Purchase(req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// 成功したら空のJSONを返す（または {"message": "ok"} など）
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	// 売り切れ・取引中などでもう買えなければ 409 (invalid_transition)
+	if err := c.Usecase.Purchase(r.Context(), req); err != nil {
+		writeError(w, r, err)
+		return
 	}
+
+	// 成功したら空のJSONを返す（または {"message": "ok"} など）
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
@@ -1,161 +1,230 @@
 package controller
 
 import (
+	"db/auth"
 	"db/usecase"
 	"encoding/json"
 	"net/http"
+	"strconv"
 )
 
 type UserController struct {
-	Usecase *usecase.UserUsecase
+	Usecase  *usecase.UserUsecase
+	Sessions *usecase.SessionUsecase
 }
 
-func NewUserController(u *usecase.UserUsecase) *UserController {
-	return &UserController{Usecase: u}
+func NewUserController(u *usecase.UserUsecase, s *usecase.SessionUsecase) *UserController {
+	return &UserController{Usecase: u, Sessions: s}
 }
 
-func (c *UserController) Handler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-	switch r.Method {
-	case http.MethodGet:
-		name := r.URL.Query().Get("name")
-		users, err := c.Usecase.SearchUser(name)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
-
-	case http.MethodPost:
-		var req usecase.RegisterUserReq
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
-			return
-		}
-		id, err := c.Usecase.RegisterUser(req)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]int{"id": id})
+// ログイン成功時のレスポンス (id / name と、トークン一式)
+type loginRes struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+	*usecase.TokenPair
+}
+
+// RegisterRoutes: ユーザー・ログイン・プロフィールの API
+//
+//	GET /api/users?q=ta&limit=20&offset=0 : 表示名の前方一致検索 (/api/user は旧API)
+//	GET /api/users/{id}                   : 1人分の公開プロフィール
+func (c *UserController) RegisterRoutes(rt *Router) {
+	rt.Handle("GET /api/user", c.searchUsers)
+	rt.Handle("POST /api/user", c.register)
+	rt.Handle("POST /api/register", c.register)
+	rt.Handle("GET /api/users", c.searchUsers)
+	rt.Handle("GET /api/users/{id}", c.getUser)
+	rt.Handle("POST /api/login", c.login)
+	rt.Handle("POST /api/social-login", c.socialLogin)
+	rt.Handle("POST /api/token/refresh", c.refresh)
+	rt.HandleAuth("POST /api/logout", c.logout)
+	rt.HandleAuth("GET /api/me", c.getMe)
+	rt.HandleAuth("PUT /api/me", c.updateMe)
+	rt.HandleAuth("POST /api/me/identities", c.linkSocial)
+}
+
+func (c *UserController) register(w http.ResponseWriter, r *http.Request) {
+	var req usecase.RegisterUserReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	id, err := c.Usecase.RegisterUser(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"id": id})
 }
 
-func (c *UserController) HandleRegister(w http.ResponseWriter, r *http.Request) {
-	// 1. CORS設定 (フロントエンドからのアクセスを許可)
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+func (c *UserController) getUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "user")
+	if !ok {
+		return
+	}
+	user, err := c.Usecase.GetPublicProfile(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// searchUsers: ?q= (旧API互換で ?name= も可) の前方一致検索
+func (c *UserController) searchUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := q.Get("q")
+	if query == "" {
+		query = q.Get("name")
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	offset, _ := strconv.Atoi(q.Get("offset"))
 
-	// プリフライトリクエスト(OPTIONS)への対応
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+	result, err := c.Usecase.SearchUsers(r.Context(), query, limit, offset)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
 
-	// 2. POSTメソッド以外は拒否
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (c *UserController) login(w http.ResponseWriter, r *http.Request) {
+	var req usecase.LoginReq
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// 3. リクエストボディ(JSON)を読み取る
-	var req usecase.RegisterUserReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+	id, err := c.Usecase.Login(r.Context(), req)
+	if err != nil {
+		// ログイン失敗は 401 (invalid_credentials)
+		writeError(w, r, err)
 		return
 	}
 
-	// 4. ユースケースを呼び出して登録処理を実行
-	id, err := c.Usecase.RegisterUser(req)
+	// セッションを作ってトークンを発行
+	tokens, err := c.Sessions.StartSession(r.Context(), id)
 	if err != nil {
-		// エラー内容をそのまま返す（"invalid name" や "user already exists" など）
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	// 5. 成功したら登録されたIDを返す
+	// 成功したらIDとトークンを返す
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"id": id})
+	json.NewEncoder(w).Encode(loginRes{ID: id, TokenPair: tokens})
 }
 
-func (c *UserController) LoginHandler(w http.ResponseWriter, r *http.Request) {
-	// CORS設定
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+func (c *UserController) socialLogin(w http.ResponseWriter, r *http.Request) {
+	// 1. リクエスト読み込み
+	var req usecase.SocialLoginReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
 
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+	// 2. Usecase呼び出し (ID トークンの検証に失敗したら 401)
+	// 同じメアドのパスワードユーザーがいれば 409: パスワードでログインしてから紐付けてもらう
+	id, name, err := c.Usecase.SocialLogin(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 3. セッションを作ってトークンを発行
+	tokens, err := c.Sessions.StartSession(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	var req usecase.LoginReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+	// 4. 結果返却
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loginRes{ID: id, Name: name, TokenPair: tokens})
+}
+
+// refresh: リフレッシュトークンで新しいトークン一式を発行する
+func (c *UserController) refresh(w http.ResponseWriter, r *http.Request) {
+	var req usecase.RefreshReq
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	id, err := c.Usecase.Login(req)
+	// 期限切れ・ログアウト済みなら 401: もう一度ログインしてもらう
+	tokens, err := c.Sessions.Refresh(r.Context(), req)
 	if err != nil {
-		// ログイン失敗 (401 Unauthorized)
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 
-	// 成功したらIDを返す
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"id": id})
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// logout: 今のセッションを失効させる (アクセストークン・リフレッシュトークンとも使えなくなる)
+func (c *UserController) logout(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUser(w, r); !ok {
+		return
+	}
+	sessionID, _ := auth.SessionIDFromContext(r.Context())
+	if err := c.Sessions.Logout(r.Context(), sessionID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "logged_out"})
 }
 
-func (c *UserController) HandleSocialLogin(w http.ResponseWriter, r *http.Request) {
-	// 1. CORS設定
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+// getMe: 自分のプロフィール
+func (c *UserController) getMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	profile, err := c.Usecase.GetProfile(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
 
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+// updateMe: 自分のプロフィールの更新
+func (c *UserController) updateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
+	var req usecase.UpdateProfileReq
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := c.Usecase.UpdateProfile(r.Context(), userID, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+}
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// linkSocial: ログイン中のアカウントに Google アカウントを紐付ける
+func (c *UserController) linkSocial(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUser(w, r)
+	if !ok {
 		return
 	}
 
-	// 2. リクエスト読み込み
 	var req usecase.SocialLoginReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid json", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// 3. Usecase呼び出し
-	id, name, err := c.Usecase.SocialLogin(req)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.Usecase.LinkSocialLogin(r.Context(), userID, req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// 4. 結果返却
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":   id,
-		"name": name,
-	})
+	json.NewEncoder(w).Encode(map[string]string{"status": "linked"})
 }
@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"db/model"
+)
+
+type CategoryDao struct {
+	db traceDB
+}
+
+func NewCategoryDao(db *sql.DB) *CategoryDao {
+	return &CategoryDao{db: traceDB{db}}
+}
+
+// List: 全カテゴリ (表示順)。数は多くないので木は usecase で組み立てる
+func (dao *CategoryDao) List(ctx context.Context) ([]model.Category, error) {
+	rows, err := dao.db.QueryContext(ctx, "SELECT id, name, parent_id, sort_order FROM categories ORDER BY sort_order, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []model.Category
+	for rows.Next() {
+		var c model.Category
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Name, &parentID, &c.SortOrder); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			c.ParentID = &id
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+func (dao *CategoryDao) Insert(ctx context.Context, c *model.Category) (int, error) {
+	result, err := dao.db.ExecContext(ctx, "INSERT INTO categories (name, parent_id, sort_order) VALUES (?, ?, ?)", c.Name, c.ParentID, c.SortOrder)
+	if err != nil {
+		return 0, err
+	}
+	id64, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id64), nil
+}
+
+func (dao *CategoryDao) Update(ctx context.Context, c *model.Category) error {
+	_, err := dao.db.ExecContext(ctx, "UPDATE categories SET name = ?, parent_id = ?, sort_order = ? WHERE id = ?", c.Name, c.ParentID, c.SortOrder, c.ID)
+	return err
+}
+
+func (dao *CategoryDao) Delete(ctx context.Context, id int) error {
+	_, err := dao.db.ExecContext(ctx, "DELETE FROM categories WHERE id = ?", id)
+	return err
+}
+
+// CountItems: そのカテゴリに直接登録されている商品の数 (取り下げたものも含む)
+func (dao *CategoryDao) CountItems(ctx context.Context, id int) (int, error) {
+	var n int
+	err := dao.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM items WHERE category_id = ?", id).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"db/model"
+)
+
+type ImageDao struct {
+	db traceDB
+}
+
+func NewImageDao(db *sql.DB) *ImageDao {
+	return &ImageDao{db: traceDB{db}}
+}
+
+// 画像取得用の列 (scanImage と順番を合わせる)
+const imageColumns = `img.id, img.owner_id, img.storage_key, img.content_type, img.width, img.height, img.size, img.created_at`
+
+func scanImage(row rowScanner) (model.Image, error) {
+	var img model.Image
+	err := row.Scan(&img.ID, &img.OwnerID, &img.StorageKey, &img.ContentType, &img.Width, &img.Height, &img.Size, &img.CreatedAt)
+	img.URL = model.ImageURL(img.ID)
+	return img, err
+}
+
+func (dao *ImageDao) queryImages(ctx context.Context, query string, args ...any) ([]model.Image, error) {
+	rows, err := dao.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []model.Image
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// Insert: 画像とサイズ違い (img.Variants) をまとめて登録する
+func (dao *ImageDao) Insert(ctx context.Context, img *model.Image) (int, error) {
+	tx, err := dao.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "INSERT INTO images (owner_id, storage_key, content_type, width, height, size) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := tx.ExecContext(ctx, query, img.OwnerID, img.StorageKey, img.ContentType, img.Width, img.Height, img.Size)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	id64, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, v := range img.Variants {
+		query := `
+			INSERT INTO image_variants (image_id, size, content_type, storage_key, width, height, bytes)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, id64, v.Size, v.ContentType, v.StorageKey, v.Width, v.Height, v.Bytes); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(id64), nil
+}
+
+// FindVariants: 指定したサイズの画像 (形式違い)。サイズ違いを作る前の画像なら空
+func (dao *ImageDao) FindVariants(ctx context.Context, imageID int, size model.ImageSize) ([]model.ImageVariant, error) {
+	query := "SELECT size, content_type, storage_key, width, height, bytes FROM image_variants WHERE image_id = ? AND size = ?"
+	rows, err := dao.db.QueryContext(ctx, query, imageID, size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []model.ImageVariant
+	for rows.Next() {
+		var v model.ImageVariant
+		if err := rows.Scan(&v.Size, &v.ContentType, &v.StorageKey, &v.Width, &v.Height, &v.Bytes); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, nil
+}
+
+// FindByID: 見つからなければ nil
+func (dao *ImageDao) FindByID(ctx context.Context, id int) (*model.Image, error) {
+	img, err := scanImage(dao.db.QueryRowContext(ctx, "SELECT "+imageColumns+" FROM images img WHERE img.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+// FindByIDs: 指定したIDの画像 (順番は不定、見つからないIDは飛ばす)
+func (dao *ImageDao) FindByIDs(ctx context.Context, ids []int) ([]model.Image, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return dao.queryImages(ctx, "SELECT "+imageColumns+" FROM images img WHERE img.id IN ("+placeholders(len(ids))+")", args...)
+}
+
+// FindByItem: 商品に紐付いた画像 (表示順)
+func (dao *ImageDao) FindByItem(ctx context.Context, itemID int) ([]model.Image, error) {
+	query := "SELECT " + imageColumns + `
+		FROM item_images ii
+		JOIN images img ON ii.image_id = img.id
+		WHERE ii.item_id = ?
+		ORDER BY ii.position`
+	return dao.queryImages(ctx, query, itemID)
+}
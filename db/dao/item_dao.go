@@ -1,54 +1,264 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
 	"db/model"
 	"fmt"
+	"strings"
 )
 
 type ItemDao struct {
-	db *sql.DB
+	db traceDB
 }
 
 func NewItemDao(db *sql.DB) *ItemDao {
-	return &ItemDao{db: db}
+	return &ItemDao{db: traceDB{db}}
 }
 
-func (dao *ItemDao) GetItems() ([]model.Item, error) {
+// 商品取得用の列 (scanItem と順番を合わせる)
+const itemColumns = `
+	i.id, i.name, i.category_id, c.name as category_name, i.price, i.description, i.status, i.seller_id, i.image_name,
+	i.created_at, i.updated_at`
+
+func scanItem(row rowScanner) (model.Item, error) {
+	var i model.Item
+	err := row.Scan(&i.ID, &i.Name, &i.CategoryID, &i.CategoryName, &i.Price, &i.Description, &i.Status, &i.SellerID, &i.ImageName,
+		&i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+func (dao *ItemDao) queryItems(ctx context.Context, query string, args ...any) ([]model.Item, error) {
+	rows, err := dao.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.Item
+	for rows.Next() {
+		i, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, nil
+}
+
+// 並び順ごとの並び替えキーと向き
+var itemSortKeys = map[string]struct {
+	expr string
+	desc bool
+}{
+	model.ItemSortNewest:    {"i.created_at", true},
+	model.ItemSortPriceAsc:  {"i.price", false},
+	model.ItemSortPriceDesc: {"i.price", true},
+	model.ItemSortPopular:   {"i.message_count", true}, // MessageDao.Create で増やす列 (migrations/010)
+}
+
+// itemFilter: 絞り込み条件の WHERE 句 (ページング位置は含まない)
+func itemFilter(q model.ItemQuery) (string, []any) {
+	conds := []string{"1 = 1"}
+	var args []any
+	if len(q.CategoryIDs) > 0 {
+		conds = append(conds, "i.category_id IN ("+placeholders(len(q.CategoryIDs))+")")
+		for _, id := range q.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+	if q.Status != "" {
+		conds = append(conds, "i.status = ?")
+		args = append(args, q.Status)
+	}
+	if !q.IncludeHidden {
+		conds = append(conds, "i.status NOT IN (?, ?)")
+		args = append(args, model.ItemStatusDraft, model.ItemStatusWithdrawn)
+	}
+	if q.MinPrice > 0 {
+		conds = append(conds, "i.price >= ?")
+		args = append(args, q.MinPrice)
+	}
+	if q.MaxPrice > 0 {
+		conds = append(conds, "i.price <= ?")
+		args = append(args, q.MaxPrice)
+	}
+	if q.SellerID != 0 {
+		conds = append(conds, "i.seller_id = ?")
+		args = append(args, q.SellerID)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// ListItems: 条件に合う商品を q.Limit 件まで返す
+// 続きがあれば、次のページの取得に使うカーソルも返す
+func (dao *ItemDao) ListItems(ctx context.Context, q model.ItemQuery) ([]model.Item, *model.ItemCursor, error) {
+	key, ok := itemSortKeys[q.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown sort: %q", q.Sort)
+	}
+	where, args := itemFilter(q)
+
+	// キーセットページング: (並び替えキー, ID) が前のページの最後より後ろのものだけ
+	op, dir := ">", "ASC"
+	if key.desc {
+		op, dir = "<", "DESC"
+	}
+	if q.After != nil {
+		var v any = q.After.Value
+		if q.Sort == model.ItemSortNewest {
+			v = q.After.CreatedAt
+		}
+		where += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND i.id %[2]s ?))", key.expr, op)
+		args = append(args, v, v, q.After.ID)
+	}
+
 	query := `
-		SELECT 
-			i.id, i.name, c.name as category_name, i.price, i.description, i.status, i.seller_id, i.image_name
+		SELECT ` + itemColumns + `, ` + key.expr + ` AS sort_key
 		FROM items i
 		JOIN categories c ON i.category_id = c.id
-	`
-	rows, err := dao.db.Query(query)
+		WHERE ` + where + `
+		ORDER BY sort_key ` + dir + `, i.id ` + dir + `
+		LIMIT ?`
+	// 1件多く取って、次のページがあるか判定する
+	args = append(args, q.Limit+1)
+
+	rows, err := dao.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query error: %v", err)
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	var items []model.Item
+	var next *model.ItemCursor
+	var lastKey any
 	for rows.Next() {
+		// q.Limit+1 件目があれば、q.Limit 件目の位置を次のカーソルにする
+		if len(items) == q.Limit {
+			next = newItemCursor(q.Sort, items[len(items)-1], lastKey)
+			break
+		}
 		var i model.Item
-		// Scanの順番に注意
-		if err := rows.Scan(&i.ID, &i.Name, &i.CategoryName, &i.Price, &i.Description, &i.Status, &i.SellerID, &i.ImageName); err != nil {
-			return nil, err
+		if err := rows.Scan(&i.ID, &i.Name, &i.CategoryID, &i.CategoryName, &i.Price, &i.Description, &i.Status, &i.SellerID, &i.ImageName,
+			&i.CreatedAt, &i.UpdatedAt, &lastKey); err != nil {
+			return nil, nil, err
 		}
 		items = append(items, i)
 	}
+	return items, next, rows.Err()
+}
+
+func newItemCursor(sort string, last model.Item, sortKey any) *model.ItemCursor {
+	c := &model.ItemCursor{Sort: sort, ID: last.ID}
+	switch sort {
+	case model.ItemSortNewest:
+		c.CreatedAt = last.CreatedAt
+	case model.ItemSortPopular:
+		n, _ := sortKey.(int64)
+		c.Value = int(n)
+	default:
+		c.Value = last.Price
+	}
+	return c
+}
+
+// CountItems: 絞り込み条件に合う商品の総数
+func (dao *ItemDao) CountItems(ctx context.Context, q model.ItemQuery) (int, error) {
+	where, args := itemFilter(q)
+	var n int
+	err := dao.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM items i WHERE "+where, args...).Scan(&n)
+	return n, err
+}
+
+// FindByID: 商品を1件取得する。見つからなければ nil
+func (dao *ItemDao) FindByID(ctx context.Context, id int) (*model.Item, error) {
+	query := `
+		SELECT ` + itemColumns + `
+		FROM items i
+		JOIN categories c ON i.category_id = c.id
+		WHERE i.id = ?`
+	i, err := scanItem(dao.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// FindByIDs: ids の順番で商品を返す (見つからないIDは飛ばす)
+func (dao *ItemDao) FindByIDs(ctx context.Context, ids []int) ([]model.Item, error) {
+	if len(ids) == 0 {
+		return []model.Item{}, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := `
+		SELECT ` + itemColumns + `
+		FROM items i
+		JOIN categories c ON i.category_id = c.id
+		WHERE i.id IN (` + placeholders(len(ids)) + `)`
+	found, err := dao.queryItems(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]model.Item, len(found))
+	for _, item := range found {
+		byID[item.ID] = item
+	}
+	items := make([]model.Item, 0, len(ids))
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			items = append(items, item)
+		}
+	}
 	return items, nil
 }
 
+// FindRelated: 同じカテゴリの販売中の商品 (新しい順、excludeID の商品は除く)
+func (dao *ItemDao) FindRelated(ctx context.Context, categoryID, excludeID, limit int) ([]model.Item, error) {
+	query := `
+		SELECT ` + itemColumns + `
+		FROM items i
+		JOIN categories c ON i.category_id = c.id
+		WHERE i.category_id = ? AND i.id <> ? AND i.status = ?
+		ORDER BY i.created_at DESC, i.id DESC
+		LIMIT ?`
+	return dao.queryItems(ctx, query, categoryID, excludeID, model.ItemStatusOnSale, limit)
+}
+
+// FindSellerSummary: 出品者の表示名と販売実績。ユーザーがいなければ nil
+func (dao *ItemDao) FindSellerSummary(ctx context.Context, sellerID int) (*model.SellerSummary, error) {
+	query := `
+		SELECT
+			u.id, COALESCE(NULLIF(u.display_name, ''), u.name), u.avatar_url,
+			(SELECT COUNT(*) FROM transactions t JOIN items i ON t.item_id = i.id WHERE i.seller_id = u.id) AS sales_count
+		FROM users u
+		WHERE u.id = ?`
+	var s model.SellerSummary
+	err := dao.db.QueryRowContext(ctx, query, sellerID).Scan(&s.ID, &s.DisplayName, &s.AvatarURL, &s.SalesCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
 // Insert: 商品出品
-func (dao *ItemDao) Insert(item *model.Item) (int, error) {
-	tx, err := dao.db.Begin()
+func (dao *ItemDao) Insert(ctx context.Context, item *model.Item) (int, error) {
+	tx, err := dao.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
 
-	// statusはデフォルト(ON_SALE)任せ
-	query := `INSERT INTO items (seller_id, category_id, name, price, description, image_name) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := tx.Exec(query, item.SellerID, item.CategoryID, item.Name, item.Price, item.Description, item.ImageName)
+	query := `INSERT INTO items (seller_id, category_id, name, price, description, image_name, status) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, item.SellerID, item.CategoryID, item.Name, item.Price, item.Description, coverImage(item.ImageIDs), item.Status)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
@@ -60,8 +270,187 @@ func (dao *ItemDao) Insert(item *model.Item) (int, error) {
 		return 0, err
 	}
 
+	// 出品もステータス履歴に残す (変更前は null)
+	if err := insertStatusHistory(ctx, tx, int(id64), nil, item.Status, item.SellerID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := replaceItemImages(ctx, tx, int(id64), item.ImageIDs); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, err
 	}
 	return int(id64), nil
 }
+
+// UpdateListing: 出品内容を更新する。価格が変わったら履歴も残す
+// 出品者本人の編集できるステータスの商品でなければ更新せず false を返す (購入と同時に来ても売れた後は変えない)
+func (dao *ItemDao) UpdateListing(ctx context.Context, item *model.Item) (bool, error) {
+	tx, err := dao.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var oldPrice int
+	var status model.ItemStatus
+	err = tx.QueryRowContext(ctx, "SELECT price, status FROM items WHERE id = ? AND seller_id = ? FOR UPDATE",
+		item.ID, item.SellerID).Scan(&oldPrice, &status)
+	if err == sql.ErrNoRows || (err == nil && !status.Editable()) {
+		tx.Rollback()
+		return false, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	query := `UPDATE items SET category_id = ?, name = ?, price = ?, description = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, item.CategoryID, item.Name, item.Price, item.Description, item.ID); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	// ImageIDs が nil なら画像はそのまま
+	if item.ImageIDs != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE items SET image_name = ? WHERE id = ?", coverImage(item.ImageIDs), item.ID); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		if err := replaceItemImages(ctx, tx, item.ID, item.ImageIDs); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if oldPrice != item.Price {
+		query := "INSERT INTO item_price_history (item_id, old_price, new_price) VALUES (?, ?, ?)"
+		if _, err := tx.ExecContext(ctx, query, item.ID, oldPrice, item.Price); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ChangeStatus: ステータスを to に変更し、履歴を残す
+// 現在のステータス・出品者・購入者 (いなければ 0) を check に渡し、check がエラーを返したら変更しない
+// 行ロックを取ってから確認するので、購入など他の変更と同時に来ても遷移表に反する変更は起きない
+func (dao *ItemDao) ChangeStatus(ctx context.Context, itemID, actorID int, to model.ItemStatus, check func(from model.ItemStatus, sellerID, buyerID int) error) error {
+	tx, err := dao.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var from model.ItemStatus
+	var sellerID, buyerID int
+	query := `
+		SELECT i.status, i.seller_id,
+			COALESCE((SELECT t.buyer_id FROM transactions t WHERE t.item_id = i.id ORDER BY t.id DESC LIMIT 1), 0)
+		FROM items i
+		WHERE i.id = ?
+		FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, itemID).Scan(&from, &sellerID, &buyerID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := check(from, sellerID, buyerID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE items SET status = ? WHERE id = ?", to, itemID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := insertStatusHistory(ctx, tx, itemID, &from, to, actorID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceItemImages: 商品の画像を imageIDs (表示順) で置き換える
+func replaceItemImages(ctx context.Context, tx traceTx, itemID int, imageIDs []int) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM item_images WHERE item_id = ?", itemID); err != nil {
+		return err
+	}
+	for i, imageID := range imageIDs {
+		query := "INSERT INTO item_images (item_id, image_id, position) VALUES (?, ?, ?)"
+		if _, err := tx.ExecContext(ctx, query, itemID, imageID, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// coverImage: 一覧に出す画像 (1枚目のサムネイル) の URL。画像がなければ空文字
+func coverImage(imageIDs []int) string {
+	if len(imageIDs) == 0 {
+		return ""
+	}
+	return model.ImageVariantURL(imageIDs[0], model.ImageSizeThumb)
+}
+
+// insertStatusHistory: ステータス変更の履歴を残す (from が nil なら出品時)
+func insertStatusHistory(ctx context.Context, tx traceTx, itemID int, from *model.ItemStatus, to model.ItemStatus, actorID int) error {
+	query := "INSERT INTO item_status_history (item_id, from_status, to_status, actor_id) VALUES (?, ?, ?, ?)"
+	_, err := tx.ExecContext(ctx, query, itemID, from, to, actorID)
+	return err
+}
+
+// GetStatusHistory: ステータス変更の履歴 (古い順)
+func (dao *ItemDao) GetStatusHistory(ctx context.Context, itemID int) ([]model.ItemStatusChange, error) {
+	query := "SELECT from_status, to_status, actor_id, changed_at FROM item_status_history WHERE item_id = ? ORDER BY changed_at, id"
+	rows, err := dao.db.QueryContext(ctx, query, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []model.ItemStatusChange
+	for rows.Next() {
+		var c model.ItemStatusChange
+		var from sql.NullString
+		if err := rows.Scan(&from, &c.ToStatus, &c.ActorID, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			status := model.ItemStatus(from.String)
+			c.FromStatus = &status
+		}
+		history = append(history, c)
+	}
+	return history, nil
+}
+
+// GetPriceHistory: 価格変更の履歴 (古い順)
+func (dao *ItemDao) GetPriceHistory(ctx context.Context, itemID int) ([]model.PriceChange, error) {
+	query := "SELECT old_price, new_price, changed_at FROM item_price_history WHERE item_id = ? ORDER BY changed_at, id"
+	rows, err := dao.db.QueryContext(ctx, query, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []model.PriceChange
+	for rows.Next() {
+		var p model.PriceChange
+		if err := rows.Scan(&p.OldPrice, &p.NewPrice, &p.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, p)
+	}
+	return history, nil
+}
+
+// placeholders: IN 句用の "?, ?, ?" (n 個)
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
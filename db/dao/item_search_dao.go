@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"db/model"
+	"db/search"
+	"strings"
+	"unicode/utf8"
+)
+
+// ItemSearchDao: MySQL の FULLTEXT インデックス (ngram パーサー) を使った商品検索
+// 正規化 (全角半角・ひらがなカタカナ) は MySQL ではできないので、
+// 正規化した文字列を search_name / search_body に保存して検索する
+type ItemSearchDao struct {
+	db traceDB
+}
+
+func NewItemSearchDao(db *sql.DB) *ItemSearchDao {
+	return &ItemSearchDao{db: traceDB{db}}
+}
+
+// Index: 商品の検索用カラムを更新する
+func (dao *ItemSearchDao) Index(ctx context.Context, item *model.Item) error {
+	query := "UPDATE items SET search_name = ?, search_body = ? WHERE id = ?"
+	_, err := dao.db.ExecContext(ctx, query, search.Normalize(item.Name), search.Normalize(item.Name+"\n"+item.Description), item.ID)
+	return err
+}
+
+// Remove: 検索にかからないように検索用カラムを空にする
+func (dao *ItemSearchDao) Remove(ctx context.Context, itemID int) error {
+	_, err := dao.db.ExecContext(ctx, "UPDATE items SET search_name = '', search_body = '' WHERE id = ?", itemID)
+	return err
+}
+
+// Search: すべての語を含む商品を関連度順に返す (商品名での一致を重く数える)
+func (dao *ItemSearchDao) Search(ctx context.Context, terms []string, limit, offset int) ([]model.SearchHit, int, error) {
+	against := booleanQuery(terms)
+	if against == "" {
+		return []model.SearchHit{}, 0, nil
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM items WHERE MATCH(search_name, search_body) AGAINST (? IN BOOLEAN MODE)"
+	if err := dao.db.QueryRowContext(ctx, countQuery, against).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id,
+			MATCH(search_name, search_body) AGAINST (? IN BOOLEAN MODE)
+			+ 2 * MATCH(search_name) AGAINST (? IN BOOLEAN MODE) AS score
+		FROM items
+		WHERE MATCH(search_name, search_body) AGAINST (? IN BOOLEAN MODE)
+		ORDER BY score DESC, id DESC
+		LIMIT ? OFFSET ?`
+	rows, err := dao.db.QueryContext(ctx, query, against, against, against, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	hits := []model.SearchHit{}
+	for rows.Next() {
+		var h model.SearchHit
+		if err := rows.Scan(&h.ItemID, &h.Score); err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, total, rows.Err()
+}
+
+// booleanQuery: 語を BOOLEAN MODE の検索式にする (すべて必須)
+//
+//	2文字以上: +"スマホ" (n-gram の並びで一致)
+//	1文字    : +ス*      (その文字で始まる n-gram に一致)
+func booleanQuery(terms []string) string {
+	var parts []string
+	for _, term := range terms {
+		// 検索式の演算子になる記号は取り除く
+		term = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`+-<>()~*"@`, r) {
+				return -1
+			}
+			return r
+		}, term)
+		switch utf8.RuneCountInString(term) {
+		case 0:
+		case 1:
+			parts = append(parts, "+"+term+"*")
+		default:
+			parts = append(parts, `+"`+term+`"`)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,28 +1,41 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
 	"db/model"
 )
 
 type MessageDao struct {
-	db *sql.DB
+	db traceDB
 }
 
 func NewMessageDao(db *sql.DB) *MessageDao {
-	return &MessageDao{db: db}
+	return &MessageDao{db: traceDB{db}}
 }
 
-// Create: メッセージを保存
-func (dao *MessageDao) Create(msg *model.Message) error {
+// Create: メッセージを保存し、商品の問い合わせ数 (人気順の並び替えキー) を増やす
+func (dao *MessageDao) Create(ctx context.Context, msg *model.Message) error {
+	tx, err := dao.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
 	// item_id を追加してINSERT
 	query := "INSERT INTO messages (item_id, sender_id, receiver_id, content) VALUES (?, ?, ?, ?)"
-	_, err := dao.db.Exec(query, msg.ItemID, msg.SenderID, msg.ReceiverID, msg.Content)
-	return err
+	if _, err := tx.ExecContext(ctx, query, msg.ItemID, msg.SenderID, msg.ReceiverID, msg.Content); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE items SET message_count = message_count + 1 WHERE id = ?", msg.ItemID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetConversation: 「特定の商品」についての「2人のユーザー」の会話を取得
-func (dao *MessageDao) GetConversation(itemID, user1, user2 int) ([]model.Message, error) {
+func (dao *MessageDao) GetConversation(ctx context.Context, itemID, user1, user2 int) ([]model.Message, error) {
 	// item_id が一致し、かつ (自分→相手 OR 相手→自分) のメッセージを取得
 	query := `
         SELECT id, item_id, sender_id, receiver_id, content, created_at 
@@ -31,7 +44,7 @@ func (dao *MessageDao) GetConversation(itemID, user1, user2 int) ([]model.Messag
           AND ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))
         ORDER BY created_at ASC`
 
-	rows, err := dao.db.Query(query, itemID, user1, user2, user2, user1)
+	rows, err := dao.db.QueryContext(ctx, query, itemID, user1, user2, user2, user1)
 	if err != nil {
 		return nil, err
 	}
@@ -48,17 +61,17 @@ func (dao *MessageDao) GetConversation(itemID, user1, user2 int) ([]model.Messag
 	return messages, nil
 }
 
-func (dao *MessageDao) GetNotifications(userID int) ([]model.Notification, error) {
+func (dao *MessageDao) GetNotifications(ctx context.Context, userID int) ([]model.Notification, error) {
 	query := `
         SELECT 
-            m.id, m.item_id, i.name, m.sender_id, u.name, m.content, m.created_at
+            m.id, m.item_id, i.name, m.sender_id, COALESCE(NULLIF(u.display_name, ''), u.name), m.content, m.created_at
         FROM messages m
         JOIN items i ON m.item_id = i.id
         JOIN users u ON m.sender_id = u.id
         WHERE m.receiver_id = ?
         ORDER BY m.created_at DESC
     `
-	rows, err := dao.db.Query(query, userID)
+	rows, err := dao.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
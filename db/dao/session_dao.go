@@ -0,0 +1,79 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"db/model"
+	"fmt"
+	"time"
+)
+
+// sessions テーブル (migrations/000_base_schema.up.sql)
+type SessionDao struct {
+	db traceDB
+}
+
+func NewSessionDao(db *sql.DB) *SessionDao {
+	return &SessionDao{db: traceDB{db}}
+}
+
+// Create: ログイン時にセッションを作る
+func (dao *SessionDao) Create(ctx context.Context, s *model.Session) error {
+	query := "INSERT INTO sessions (id, user_id, refresh_token_hash, expires_at) VALUES (?, ?, ?, ?)"
+	_, err := dao.db.ExecContext(ctx, query, s.ID, s.UserID, s.RefreshTokenHash, s.ExpiresAt)
+	return err
+}
+
+// FindByID: 見つからなければ (nil, nil) を返す
+func (dao *SessionDao) FindByID(ctx context.Context, id string) (*model.Session, error) {
+	query := "SELECT id, user_id, refresh_token_hash, expires_at, revoked_at, created_at FROM sessions WHERE id = ?"
+	return dao.scanOne(dao.db.QueryRowContext(ctx, query, id))
+}
+
+// FindByRefreshTokenHash: 見つからなければ (nil, nil) を返す
+func (dao *SessionDao) FindByRefreshTokenHash(ctx context.Context, hash string) (*model.Session, error) {
+	query := "SELECT id, user_id, refresh_token_hash, expires_at, revoked_at, created_at FROM sessions WHERE refresh_token_hash = ?"
+	return dao.scanOne(dao.db.QueryRowContext(ctx, query, hash))
+}
+
+func (dao *SessionDao) scanOne(row *sql.Row) (*model.Session, error) {
+	var s model.Session
+	var revokedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.RefreshTokenHash, &s.ExpiresAt, &revokedAt, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return &s, nil
+}
+
+// RotateRefreshToken: リフレッシュトークンを新しいものに差し替える
+// 古いトークンが既に使われていた(同時リフレッシュ等)場合はエラー
+func (dao *SessionDao) RotateRefreshToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	query := `
+		UPDATE sessions SET refresh_token_hash = ?, expires_at = ?
+		WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL`
+	result, err := dao.db.ExecContext(ctx, query, newHash, expiresAt, id, oldHash)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("refresh token already used")
+	}
+	return nil
+}
+
+// Revoke: ログアウト。以後このセッションのトークンは使えない
+func (dao *SessionDao) Revoke(ctx context.Context, id string) error {
+	_, err := dao.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL", id)
+	return err
+}
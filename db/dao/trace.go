@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+
+	"db/tracing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceDB: *sql.DB のクエリ1つごとにスパンを作る (DAO は *sql.DB を直接触らずにこれを使う)
+// スパンには SQL をプレースホルダのまま載せ、引数の値 (メールアドレスやパスワードのハッシュなど) は載せない
+type traceDB struct {
+	db *sql.DB
+}
+
+// traceTx: traceDB.BeginTx のトランザクション
+type traceTx struct {
+	tx *sql.Tx
+}
+
+// startQuery: クエリのスパンを始める
+// QueryContext のスパンは結果を返したところで終わる (rows.Next で読む時間は入らない)
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	op := tracing.SQLOperation(query)
+	return tracing.Tracer().Start(ctx, "db "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameMySQL,
+			semconv.DBOperationName(op),
+			semconv.DBQueryText(tracing.SanitizeSQL(query)),
+		),
+	)
+}
+
+func (t traceDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	tracing.RecordError(span, err)
+	return rows, err
+}
+
+func (t traceDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	row := t.db.QueryRowContext(ctx, query, args...)
+	tracing.RecordError(span, row.Err())
+	return row
+}
+
+func (t traceDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	res, err := t.db.ExecContext(ctx, query, args...)
+	tracing.RecordError(span, err)
+	return res, err
+}
+
+func (t traceDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (traceTx, error) {
+	tx, err := t.db.BeginTx(ctx, opts)
+	return traceTx{tx}, err
+}
+
+func (t traceTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	tracing.RecordError(span, err)
+	return rows, err
+}
+
+func (t traceTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	row := t.tx.QueryRowContext(ctx, query, args...)
+	tracing.RecordError(span, row.Err())
+	return row
+}
+
+func (t traceTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	res, err := t.tx.ExecContext(ctx, query, args...)
+	tracing.RecordError(span, err)
+	return res, err
+}
+
+func (t traceTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t traceTx) Rollback() error {
+	return t.tx.Rollback()
+}
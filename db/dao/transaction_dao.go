@@ -1,53 +1,105 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"db/apperr"
+	"db/metrics"
+	"db/model"
+	"db/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TransactionDao struct {
-	db *sql.DB
+	db traceDB
 }
 
 func NewTransactionDao(db *sql.DB) *TransactionDao {
-	return &TransactionDao{db: db}
+	return &TransactionDao{db: traceDB{db}}
 }
 
 // Purchase はトランザクションを使って「購入履歴保存」と「商品ステータス更新」を一気に行います
-func (dao *TransactionDao) Purchase(itemID int, buyerID int) error {
+// 買えるかどうかの判定は check (usecase の遷移表) に任せ、check がエラーを返したら何もしません
+// 商品が存在しなければ何もせず false を返します
+// 結果 (成功・check で断った・商品がない・DB のエラー) は purchases_total に数え (check が NotFound を返したら商品がない扱い)、トランザクション全体を1つのスパンにする
+func (dao *TransactionDao) Purchase(ctx context.Context, itemID int, buyerID int, check func(from model.ItemStatus, sellerID int) error) (bool, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TransactionDao.Purchase", trace.WithAttributes(
+		attribute.Int("item.id", itemID),
+		attribute.Int("buyer.id", buyerID),
+	))
+	defer span.End()
+
+	rejected := false
+	found, err := dao.purchase(ctx, itemID, buyerID, func(from model.ItemStatus, sellerID int) error {
+		err := check(from, sellerID)
+		rejected = err != nil
+		return err
+	})
+	result := metrics.PurchaseSuccess
+	switch {
+	case err == nil && !found:
+		result = metrics.PurchaseNotFound
+	case err == nil:
+	case rejected && apperr.KindOf(err) == apperr.NotFound:
+		// 見せていない商品 (下書き・取り下げ) は check が「ない」ことにする
+		result = metrics.PurchaseNotFound
+	case rejected:
+		result = metrics.PurchaseConflict
+	default:
+		result = metrics.PurchaseError
+		tracing.RecordError(span, err)
+	}
+	metrics.Purchases.WithLabelValues(result).Inc()
+	span.SetAttributes(attribute.String("purchase.result", result))
+	return found, err
+}
+
+func (dao *TransactionDao) purchase(ctx context.Context, itemID int, buyerID int, check func(from model.ItemStatus, sellerID int) error) (bool, error) {
 	// 1. トランザクション開始 (失敗したら全部なかったことにする機能)
-	tx, err := dao.db.Begin()
+	tx, err := dao.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	// 2. 商品がまだ売れていないかチェック (ON_SALEじゃなければエラー)
+	// 2. 商品が今買える状態かチェック
 	// FOR UPDATE をつけることで、同時に誰かが買おうとしてもロックできる
-	var status string
-	err = tx.QueryRow("SELECT status FROM items WHERE id = ? FOR UPDATE", itemID).Scan(&status)
+	var status model.ItemStatus
+	var sellerID int
+	err = tx.QueryRowContext(ctx, "SELECT status, seller_id FROM items WHERE id = ? FOR UPDATE", itemID).Scan(&status, &sellerID)
+	if err == sql.ErrNoRows {
+		// 商品がない (usecase で 404 にする)
+		tx.Rollback()
+		return false, nil
+	}
 	if err != nil {
 		tx.Rollback()
-		return err
+		return false, err
 	}
-	if status != "ON_SALE" {
+	if err := check(status, sellerID); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("item is already sold out")
+		return true, err
 	}
 
-	// 3. itemsテーブルのステータスを SOLD_OUT に更新
-	_, err = tx.Exec("UPDATE items SET status = 'SOLD_OUT' WHERE id = ?", itemID)
+	// 3. itemsテーブルのステータスを TRADING (取引中) に更新し、履歴を残す
+	_, err = tx.ExecContext(ctx, "UPDATE items SET status = ? WHERE id = ?", model.ItemStatusTrading, itemID)
 	if err != nil {
 		tx.Rollback()
-		return err
+		return true, err
+	}
+	if err := insertStatusHistory(ctx, tx, itemID, &status, model.ItemStatusTrading, buyerID); err != nil {
+		tx.Rollback()
+		return true, err
 	}
 
 	// 4. transactionsテーブルに購入記録を追加
-	_, err = tx.Exec("INSERT INTO transactions (item_id, buyer_id) VALUES (?, ?)", itemID, buyerID)
+	_, err = tx.ExecContext(ctx, "INSERT INTO transactions (item_id, buyer_id) VALUES (?, ?)", itemID, buyerID)
 	if err != nil {
 		tx.Rollback()
-		return err
+		return true, err
 	}
 
 	// 5. 全部成功したので確定！
-	return tx.Commit()
+	return true, tx.Commit()
 }
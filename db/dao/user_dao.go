@@ -1,20 +1,50 @@
 package dao
 
 import (
+	"context"
 	"database/sql"
 	"db/model"
+	"strings"
 )
 
 type UserDao struct {
-	db *sql.DB
+	db traceDB
 }
 
 func NewUserDao(db *sql.DB) *UserDao {
-	return &UserDao{db: db}
+	return &UserDao{db: traceDB{db}}
 }
 
-func (d *UserDao) FindByName(name string) ([]model.User, error) {
-	rows, err := d.db.Query("SELECT id, name, password FROM users WHERE name = ?", name)
+// users テーブルから取得する列 (scanUser と順番を合わせる)
+const userColumns = `id, name, password, email, email_verified, display_name, avatar_url, bio, is_admin, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (model.User, error) {
+	var u model.User
+	var email, bio sql.NullString
+	err := row.Scan(&u.ID, &u.Name, &u.Password, &email, &u.EmailVerified, &u.DisplayName, &u.AvatarURL, &bio, &u.IsAdmin, &u.CreatedAt, &u.UpdatedAt)
+	u.Email = email.String
+	u.Bio = bio.String
+	return u, err
+}
+
+// findOne: 1件だけ取得する。見つからなければ nil
+func (d *UserDao) findOne(ctx context.Context, query string, args ...any) (*model.User, error) {
+	u, err := scanUser(d.db.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (d *UserDao) FindByName(ctx context.Context, name string) ([]model.User, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE name = ?", name)
 	if err != nil {
 		return nil, err
 	}
@@ -22,8 +52,8 @@ func (d *UserDao) FindByName(name string) ([]model.User, error) {
 
 	var users []model.User
 	for rows.Next() {
-		var u model.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Password); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -31,28 +61,216 @@ func (d *UserDao) FindByName(name string) ([]model.User, error) {
 	return users, nil
 }
 
-func (d *UserDao) Insert(user *model.User) (int, error) {
-	tx, err := d.db.Begin()
+// FindByID: いなければ nil
+func (d *UserDao) FindByID(ctx context.Context, id int) (*model.User, error) {
+	return d.findOne(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", id)
+}
+
+// FindByEmail: いなければ nil
+func (d *UserDao) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return d.findOne(ctx, "SELECT "+userColumns+" FROM users WHERE email = ?", email)
+}
+
+func (d *UserDao) Insert(ctx context.Context, user *model.User) (int, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
-	
-	result, err := tx.Exec("INSERT INTO users (name, password) VALUES (?, ?)", user.Name, user.Password)
 
+	id, err := insertUser(ctx, tx, user)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
-	id64, err := result.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// InsertWithIdentity: ユーザーを作り、外部IDを紐付ける (ソーシャルログインの新規登録)
+// 1つのトランザクションで行うので、紐付けに失敗したらユーザーも作られない
+func (d *UserDao) InsertWithIdentity(ctx context.Context, user *model.User, identity *model.Identity) (int, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := insertUser(ctx, tx, user)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
+	query := "INSERT INTO user_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?)"
+	if _, err := tx.ExecContext(ctx, query, id, identity.Provider, identity.Subject, identity.Email); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return 0, err
 	}
 
+	return id, nil
+}
+
+func insertUser(ctx context.Context, tx traceTx, user *model.User) (int, error) {
+	// email は UNIQUE なので、未登録のときは空文字ではなく NULL で入れる
+	query := `INSERT INTO users (name, password, email, email_verified, display_name, avatar_url) VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, user.Name, user.Password, nullIfEmpty(user.Email), user.EmailVerified, user.DisplayName, user.AvatarURL)
+	if err != nil {
+		return 0, err
+	}
+
+	id64, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
 	return int(id64), nil
 }
+
+// UpdateProfile: プロフィール(表示名・アバター・自己紹介)を更新する
+func (d *UserDao) UpdateProfile(ctx context.Context, user *model.User) error {
+	query := "UPDATE users SET display_name = ?, avatar_url = ?, bio = ? WHERE id = ?"
+	_, err := d.db.ExecContext(ctx, query, user.DisplayName, user.AvatarURL, user.Bio, user.ID)
+	return err
+}
+
+// SetEmail: メールアドレスと確認済みフラグを設定する
+func (d *UserDao) SetEmail(ctx context.Context, id int, email string, verified bool) error {
+	_, err := d.db.ExecContext(ctx, "UPDATE users SET email = ?, email_verified = ? WHERE id = ?", nullIfEmpty(email), verified, id)
+	return err
+}
+
+// FindByIdentity: 外部ID(プロバイダーと sub)に紐付いたユーザーを探す。いなければ nil
+func (d *UserDao) FindByIdentity(ctx context.Context, provider, subject string) (*model.User, error) {
+	query := `
+		SELECT u.id, u.name, u.password, u.email, u.email_verified, u.display_name, u.avatar_url, u.bio, u.is_admin, u.created_at, u.updated_at
+		FROM user_identities ui
+		JOIN users u ON ui.user_id = u.id
+		WHERE ui.provider = ? AND ui.subject = ?`
+	return d.findOne(ctx, query, provider, subject)
+}
+
+// LinkIdentity: ユーザーに外部IDを紐付ける ((provider, subject) はユニーク)
+func (d *UserDao) LinkIdentity(ctx context.Context, identity *model.Identity) error {
+	query := "INSERT INTO user_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?)"
+	_, err := d.db.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	return err
+}
+
+// ListIdentities: ユーザーに紐付いている外部IDの一覧
+func (d *UserDao) ListIdentities(ctx context.Context, userID int) ([]model.Identity, error) {
+	query := "SELECT id, user_id, provider, subject, email, created_at FROM user_identities WHERE user_id = ? ORDER BY id"
+	rows, err := d.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []model.Identity
+	for rows.Next() {
+		var i model.Identity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	return identities, nil
+}
+
+// UpdatePassword: パスワード(ハッシュ値)を書き換える。ログイン時の再ハッシュで使う
+func (d *UserDao) UpdatePassword(ctx context.Context, id int, password string) error {
+	_, err := d.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", password, id)
+	return err
+}
+
+// FindPlaintextPasswordUsers: bcrypt ハッシュになっていない(平文のままの)ユーザーを探す
+// パスワードが空のユーザー(ソーシャルログイン専用)は対象外
+func (d *UserDao) FindPlaintextPasswordUsers(ctx context.Context) ([]model.User, error) {
+	query := `
+		SELECT id, name
+		FROM users
+		WHERE password <> ''
+		  AND NOT (CHAR_LENGTH(password) = 60 AND password LIKE '$2_$%')
+		ORDER BY id`
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var u model.User
+		if err := rows.Scan(&u.ID, &u.Name); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// 公開プロフィール用の列 (出品数は items から数える)
+// 下書き・取り下げた商品は数えないので、クエリの先頭に publicUserArgs を渡す
+const publicUserColumns = `
+	u.id, u.display_name, u.avatar_url, u.bio, u.created_at,
+	(SELECT COUNT(*) FROM items i WHERE i.seller_id = u.id AND i.status NOT IN (?, ?)) AS listing_count`
+
+var publicUserArgs = []any{model.ItemStatusDraft, model.ItemStatusWithdrawn}
+
+func scanPublicUser(row rowScanner) (model.PublicUser, error) {
+	var u model.PublicUser
+	var bio sql.NullString
+	err := row.Scan(&u.ID, &u.DisplayName, &u.AvatarURL, &bio, &u.JoinedAt, &u.ListingCount)
+	u.Bio = bio.String
+	return u, err
+}
+
+// FindPublicByID: 公開プロフィールを取得する。いなければ nil
+func (d *UserDao) FindPublicByID(ctx context.Context, id int) (*model.PublicUser, error) {
+	u, err := scanPublicUser(d.db.QueryRowContext(ctx, "SELECT "+publicUserColumns+" FROM users u WHERE u.id = ?", append(publicUserArgs, id)...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SearchPublic: 表示名の前方一致で検索する (ID順、limit/offset でページング)
+func (d *UserDao) SearchPublic(ctx context.Context, prefix string, limit, offset int) ([]model.PublicUser, error) {
+	query := "SELECT " + publicUserColumns + `
+		FROM users u
+		WHERE u.display_name LIKE ?
+		ORDER BY u.id
+		LIMIT ? OFFSET ?`
+	rows, err := d.db.QueryContext(ctx, query, append(publicUserArgs, escapeLike(prefix)+"%", limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.PublicUser
+	for rows.Next() {
+		u, err := scanPublicUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// escapeLike: LIKE のワイルドカード(% と _)を文字として扱うようにエスケープする
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// マイグレーション中は MySQL の名前付きロックを取る (Cloud Run の複数インスタンスが同時に流さないように)
+const (
+	migrationLockName    = "schema_migrations"
+	defaultMigrationWait = 60 * time.Second
+)
+
+var (
+	ErrMigrationLocked = errors.New("another migration is running")
+	ErrSchemaOutdated  = errors.New("schema is not at the expected version")
+)
+
+// Migration: バージョン1つ分の SQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus: 適用状況 (AppliedAt が nil なら未適用)
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations: NNN_名前.up.sql / NNN_名前.down.sql を読み込む (バージョン順)
+// up と down は必ず両方必要
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: file name must be NNN_name.up.sql or NNN_name.down.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: two different names (%s, %s)", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %03d_%s: both up and down files are required", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+	LockWait   time.Duration // ロックを待つ時間
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations, LockWait: defaultMigrationWait}, nil
+}
+
+// Up: 未適用のマイグレーションをすべて適用する。適用したものを返す
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.Migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := execScript(ctx, conn, mig.Up); err != nil {
+				return fmt.Errorf("migration %03d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", mig.Version, mig.Name); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down: 新しい方から steps 個のマイグレーションを戻す。戻したものを返す
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.Migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if err := execScript(ctx, conn, mig.Down); err != nil {
+				return fmt.Errorf("migration %03d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status: すべてのマイグレーションの適用状況 (バージョン順)
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureMigrationTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.Migrations))
+	for i, mig := range m.Migrations {
+		statuses[i] = MigrationStatus{Migration: mig}
+		if at, ok := done[mig.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Check: 埋め込んだマイグレーションがすべて適用済みか (/readyz 用)
+// Status と違って schema_migrations を作らない
+func (m *Migrator) Check(ctx context.Context) error {
+	rows, err := m.DB.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	done := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		done[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, mig := range m.Migrations {
+		if !done[mig.Version] {
+			return fmt.Errorf("%w: %03d_%s is pending", ErrSchemaOutdated, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// Baseline: version 以下を、SQL を流さずに適用済みとして記録する
+// マイグレーション導入前に手で SQL を流していた DB で、最初に1回だけ使う
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		for _, mig := range m.Migrations {
+			if mig.Version > version {
+				break
+			}
+			query := "INSERT IGNORE INTO schema_migrations (version, name) VALUES (?, ?)"
+			if _, err := conn.ExecContext(ctx, query, mig.Version, mig.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// withLock: 名前付きロックを取ってから fn を実行する
+// GET_LOCK はセッション単位なので、同じコネクションで実行する
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, int(m.LockWait.Seconds())).Scan(&got); err != nil {
+		return err
+	}
+	if got.Int64 != 1 {
+		return ErrMigrationLocked
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLockName)
+
+	if err := ensureMigrationTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureMigrationTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INT          PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			applied_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	return err
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		done[version] = at
+	}
+	return done, rows.Err()
+}
+
+// execScript: SQL ファイルを文ごとに実行する
+// MySQL の DDL はトランザクションで戻せないので、途中で失敗したら手で直す必要がある
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range SplitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// SplitStatements: SQL を ; で文に分ける (文字列の中の ; と -- コメントは無視する)
+func SplitStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	var quote rune
+	lines := strings.Split(script, "\n")
+	for _, line := range lines {
+		for i, r := range line {
+			if quote == 0 && strings.HasPrefix(line[i:], "--") {
+				break
+			}
+			switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '\'' || r == '"' || r == '`':
+				quote = r
+			case r == ';':
+				if s := strings.TrimSpace(cur.String()); s != "" {
+					stmts = append(stmts, s)
+				}
+				cur.Reset()
+				continue
+			}
+			cur.WriteRune(r)
+		}
+		cur.WriteByte('\n')
+	}
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"db/migrations"
+)
+
+func TestLoadMigrationsEmbedded(t *testing.T) {
+	migs, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) == 0 || migs[0].Version != 0 {
+		t.Fatalf("最初は 000_base_schema のはず: %+v", migs)
+	}
+	for i, m := range migs {
+		if m.Version != i {
+			t.Errorf("バージョンが連番になっていない: %d 番目が %03d_%s", i, m.Version, m.Name)
+		}
+		if len(SplitStatements(m.Up)) == 0 || len(SplitStatements(m.Down)) == 0 {
+			t.Errorf("%03d_%s: 空の SQL", m.Version, m.Name)
+		}
+		// InnoDB は1文で FULLTEXT インデックスを2つ以上作れない (ERROR 1795)
+		for _, stmt := range SplitStatements(m.Up) {
+			if strings.Count(strings.ToUpper(stmt), "FULLTEXT") > 1 {
+				t.Errorf("%03d_%s: FULLTEXT インデックスは1文に1つずつ: %s", m.Version, m.Name, stmt)
+			}
+		}
+	}
+}
+
+func TestLoadMigrationsRequiresPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_a.up.sql":   {Data: []byte("SELECT 1;")},
+		"001_a.down.sql": {Data: []byte("SELECT 1;")},
+		"002_b.up.sql":   {Data: []byte("SELECT 2;")},
+	}
+	if _, err := LoadMigrations(fsys); err == nil {
+		t.Error("down がないのにエラーにならない")
+	}
+
+	fsys = fstest.MapFS{"1_bad-name.up.sql": {Data: []byte("SELECT 1;")}}
+	if _, err := LoadMigrations(fsys); err == nil {
+		t.Error("不正なファイル名がエラーにならない")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- コメント; ここは無視
+CREATE TABLE a (id INT); -- 行末のコメント
+INSERT INTO a VALUES ('x;y'), ("--");
+UPDATE a SET id = 1`
+	got := SplitStatements(script)
+	want := []string{
+		"CREATE TABLE a (id INT)",
+		"INSERT INTO a VALUES ('x;y'), (\"--\")",
+		"UPDATE a SET id = 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
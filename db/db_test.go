@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"testing"
 
 	_ "github.com/go-sql-driver/mysql"
+
+	appdb "db/db"
+	"db/migrations"
 )
 
 // GoLandなら、この関数の左に出る「緑の三角マーク」を押すだけで実行できます
@@ -33,17 +37,17 @@ func TestDBConnection(t *testing.T) {
 	}
 	t.Log("✅ DB接続成功")
 
-	// 3. テーブル作成 (まだ作ってない場合)
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS users (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		name VARCHAR(255) NOT NULL
-	)`)
+	// 3. マイグレーションでテーブルを作る (本番と同じスキーマでテストする)
+	migrator, err := appdb.NewMigrator(db, migrations.FS)
 	if err != nil {
-		t.Fatalf("テーブル作成失敗: %v", err)
+		t.Fatalf("マイグレーション読み込み失敗: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("マイグレーション失敗: %v", err)
 	}
 
 	// 4. INSERTテスト
-	res, err := db.Exec("INSERT INTO users (name) VALUES (?)", "Test User via Go")
+	res, err := db.Exec("INSERT INTO users (name, password) VALUES (?, ?)", "Test User via Go", "")
 	if err != nil {
 		t.Fatalf("INSERT失敗: %v", err)
 	}
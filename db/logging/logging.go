@@ -0,0 +1,118 @@
+// Package logging は log/slog の設定と、リクエストごとの情報を context で運ぶ仕組み
+//
+// RequestID ミドルウェアが context に *Request を入れておくと、
+// slog.ErrorContext(ctx, ...) のようにログを出すだけで request_id・route・user_id・latency_ms が付く
+// (コントローラー・ユースケース・DAO のどこからでも同じ)
+// ctx にトレースのスパンがあれば trace_id・span_id も付くので、ログからトレースをたどれる
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Request: 1リクエスト分のログ用の情報
+// ルートとユーザーは後から分かる (Router・認証ミドルウェアが埋める) のでポインタで持ち回る
+type Request struct {
+	ID     string
+	Route  string // "GET /api/items/{id}" (どのルートにも当たらなければ空)
+	UserID int
+	Start  time.Time
+}
+
+type contextKey struct{}
+
+// WithRequest: ctx に req を入れる
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, req)
+}
+
+// RequestFrom: ctx のリクエスト情報 (HTTP リクエストの外なら nil)
+func RequestFrom(ctx context.Context) *Request {
+	req, _ := ctx.Value(contextKey{}).(*Request)
+	return req
+}
+
+// RequestID: ctx のリクエストID (なければ空)
+func RequestID(ctx context.Context) string {
+	if req := RequestFrom(ctx); req != nil {
+		return req.ID
+	}
+	return ""
+}
+
+// SetRoute: マッチしたルートを記録する
+func SetRoute(ctx context.Context, route string) {
+	if req := RequestFrom(ctx); req != nil {
+		req.Route = route
+	}
+}
+
+// SetUserID: 認証できたユーザーを記録する
+func SetUserID(ctx context.Context, userID int) {
+	if req := RequestFrom(ctx); req != nil {
+		req.UserID = userID
+	}
+}
+
+// New: format は "json" か "text"、level は debug / info / warn / error
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("logging: unknown level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lv}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", format)
+	}
+	return slog.New(NewHandler(h)), nil
+}
+
+// NewHandler: ctx にリクエスト情報があれば属性として付ける slog.Handler
+func NewHandler(h slog.Handler) slog.Handler {
+	return contextHandler{h}
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if req := RequestFrom(ctx); req != nil {
+		rec.AddAttrs(slog.String("request_id", req.ID))
+		if req.Route != "" {
+			rec.AddAttrs(slog.String("route", req.Route))
+		}
+		if req.UserID != 0 {
+			rec.AddAttrs(slog.Int("user_id", req.UserID))
+		}
+		if !req.Start.IsZero() {
+			rec.AddAttrs(slog.Float64("latency_ms", float64(time.Since(req.Start).Microseconds())/1000))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestHandlerAddsRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &Request{ID: "req-1", Start: time.Now()}
+	ctx := WithRequest(context.Background(), req)
+	SetRoute(ctx, "GET /api/items/{id}")
+	SetUserID(ctx, 42)
+	logger.With("component", "dao").ErrorContext(ctx, "query failed", "error", "boom")
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	want := map[string]any{
+		"msg":        "query failed",
+		"request_id": "req-1",
+		"route":      "GET /api/items/{id}",
+		"user_id":    float64(42),
+		"component":  "dao",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["latency_ms"]; !ok {
+		t.Error("latency_ms がない")
+	}
+}
+
+func TestHandlerWithoutRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, "info", "json")
+	logger.InfoContext(context.Background(), "startup")
+
+	var got map[string]any
+	json.Unmarshal(buf.Bytes(), &got)
+	if _, ok := got["request_id"]; ok {
+		t.Errorf("リクエストの外なのに request_id がある: %v", got)
+	}
+	if RequestID(context.Background()) != "" {
+		t.Error("RequestID should be empty")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "verbose", "json"); err == nil {
+		t.Error("unknown level should fail")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("unknown format should fail")
+	}
+	logger, _ := New(&bytes.Buffer{}, "warn", "text")
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("info should be disabled at warn level")
+	}
+}
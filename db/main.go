@@ -1,59 +1,147 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
 	"fmt" // 追加
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"db/auth"
+	"db/config"
 	"db/controller"
 	"db/dao"
 	"db/db"
+	"db/logging"
+	"db/metrics"
+	"db/migrations"
+	"db/seed"
+	"db/storage"
+	"db/tracing"
 	"db/usecase"
 )
 
 func main() {
+	// 設定: デフォルト → 設定ファイル → 環境変数 (足りない値があればまとめて表示して終了)
+	cfg, err := config.Load(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// ログは slog (LOG_FORMAT=json なら Cloud Logging でそのまま読める)
+	// log.Printf もこのハンドラーを通るので、サブコマンドの出力も同じ形式になる
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	if src := cfg.Source(); src != "" {
+		slog.Info("loaded config file", "path", src, "env", cfg.Env)
+	}
+
+	// トレース (OpenTelemetry): TRACING_EXPORTER=otlp なら OTEL_EXPORTER_OTLP_ENDPOINT に送る
+	// 手元では stdout、none (デフォルト) なら何もしない
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, cfg.Env)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// DB接続
-	dbConn, err := db.NewDB()
+	dbConn, err := db.NewDB(cfg.MySQL)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer dbConn.Close()
+	// コネクションプールの状態 (使用中・待ち回数など) を /metrics に出す
+	if err := metrics.RegisterDB("mysql", dbConn); err != nil {
+		log.Fatal(err)
+	}
+
+	// `server migrate up|down [n]|status|baseline <version>` : スキーマのマイグレーション
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(dbConn, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	//以下調整用
-	categorySQL := `
-    INSERT IGNORE INTO categories (id, name) VALUES 
-    (1, '本・雑誌'),
-    (2, '家電・スマホ'),
-    (3, 'ファッション');
-    `
-	if _, err := dbConn.Exec(categorySQL); err != nil {
-		log.Printf("カテゴリー追加エラー(無視可): %v", err)
-	} else {
-		log.Println("カテゴリーデータの準備完了")
-	}
-
-	// 2. テストユーザー (ID=1)
-	userSQL := `
-    INSERT IGNORE INTO users (id, name, password, created_at) VALUES 
-    (1, 'テスト太郎', 'pass1234', NOW());
-    `
-	if _, err := dbConn.Exec(userSQL); err != nil {
-		log.Printf("ユーザー追加エラー(無視可): %v", err)
-	} else {
-		log.Println("テストユーザー(ID=1)の準備完了")
-	}
-	////////////////
+	// MIGRATE_ON_START=1 なら起動時に未適用のマイグレーションを流す (ロックを取るので複数インスタンスでも安全)
+	if cfg.MigrateOnStart {
+		if err := runMigrate(dbConn, []string{"up"}); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// 組み立て (DI)
+	// PASSWORD_HASH_COST で bcrypt のコストを調整できる (未設定ならデフォルト)
+	hasher := auth.NewPasswordHasher(cfg.Auth.PasswordHashCost)
+
+	// ソーシャルログイン: Google の ID トークンを検証する
+	// GOOGLE_CLIENT_ID (aud) は必須。GOOGLE_JWKS_URL で公開鍵の取得先を変えられる
+	jwksURL := cfg.Auth.GoogleJWKSURL
+	if jwksURL == "" {
+		jwksURL = auth.GoogleJWKSURL
+	}
+	idTokenVerifier := auth.NewIDTokenVerifier(jwksURL, auth.GoogleIssuers, cfg.Auth.GoogleClientID)
+
 	userDao := dao.NewUserDao(dbConn)
-	userUsecase := usecase.NewUserUsecase(userDao)
-	userController := controller.NewUserController(userUsecase)
+	userUsecase := usecase.NewUserUsecase(userDao, hasher, idTokenVerifier)
+
+	// `server audit-passwords` : 平文パスワードが残っているユーザーを報告して終了
+	if len(os.Args) > 1 && os.Args[1] == "audit-passwords" {
+		auditPasswords(userUsecase)
+		return
+	}
+
+	sessionDao := dao.NewSessionDao(dbConn)
+	sessionUsecase := usecase.NewSessionUsecase(sessionDao, auth.NewTokenIssuer(tokenSecret(cfg.Auth.TokenSecret), 15*time.Minute, 30*24*time.Hour))
+	authMiddleware := controller.NewAuthMiddleware(sessionUsecase)
+
+	userController := controller.NewUserController(userUsecase, sessionUsecase)
+
+	// 画像の保存先: IMAGE_BUCKET があれば Cloud Storage、なければ IMAGE_DIR (デフォルト ./uploads)
+	imageStorage, err := newImageStorage(cfg.Images)
+	if err != nil {
+		log.Fatal(err)
+	}
+	imageDao := dao.NewImageDao(dbConn)
+	imageUsecase := usecase.NewImageUsecase(imageDao, imageStorage)
+	imageController := controller.NewImageController(imageUsecase)
+
+	categoryDao := dao.NewCategoryDao(dbConn)
+	categoryUsecase := usecase.NewCategoryUsecase(categoryDao, userDao)
+	categoryController := controller.NewCategoryController(categoryUsecase)
 
 	itemDao := dao.NewItemDao(dbConn)
-	itemUsecase := usecase.NewItemUsecase(itemDao)
+	itemSearchDao := dao.NewItemSearchDao(dbConn)
+	itemUsecase := usecase.NewItemUsecase(itemDao, itemSearchDao, imageDao, categoryDao)
+
+	// `server reindex-search` : 全商品の検索用カラムを作り直して終了
+	if len(os.Args) > 1 && os.Args[1] == "reindex-search" {
+		n, err := itemUsecase.ReindexSearch(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d 件の商品を検索インデックスに登録しました", n)
+		return
+	}
+
+	// `server seed <セット名>` : 開発・デモ用のデータを入れて終了 (何回流しても同じ結果になる)
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeed(dbConn, hasher, itemUsecase, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	itemController := controller.NewItemController(itemUsecase)
 
 	txDao := dao.NewTransactionDao(dbConn)
@@ -64,40 +152,226 @@ func main() {
 	messageUsecase := usecase.NewMessageUsecase(messageDao)
 	messageController := controller.NewMessageController(messageUsecase)
 
-	helpController := controller.NewHelpController()
+	helpController := controller.NewHelpController(cfg.Help)
 
-	geminiController := controller.NewGeminiController()
+	geminiController := controller.NewGeminiController(imageUsecase, cfg.Gemini)
 
-	// ルーティング
-	http.HandleFunc("/api/user", userController.Handler)
-	http.HandleFunc("/api/register", userController.Handler)
-	http.HandleFunc("/api/login", userController.LoginHandler)
-	http.HandleFunc("/api/items", itemController.Handler)
-	http.HandleFunc("/api/purchase", txController.Handler)
-	http.HandleFunc("/api/messages", messageController.HandleMessages)
-	http.HandleFunc("/api/notifications", messageController.HandleNotifications)
-	http.HandleFunc("/api/help", helpController.HandleHelp)
-	http.HandleFunc("/api/generate-description", geminiController.HandleGenerateDescription)
-	http.HandleFunc("/api/social-login", userController.HandleSocialLogin)
-	http.HandleFunc("/api/estimate-price", geminiController.HandleEstimatePrice)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// /readyz で見る依存先: DB とスキーマのバージョンは必須、Google の認証情報 (Gemini・ヘルプ検索) は任意
+	migrator, err := db.NewMigrator(dbConn, migrations.FS)
+	if err != nil {
+		log.Fatal(err)
 	}
+	healthController := controller.NewHealthController(
+		controller.HealthCheck{Name: "database", Check: dbConn.PingContext},
+		controller.HealthCheck{Name: "migrations", Check: migrator.Check},
+		controller.HealthCheck{Name: "google_credentials", Optional: true, Check: geminiController.CheckCredentials},
+	)
 
-	addr := fmt.Sprintf(":%s", port)
-	log.Printf("Listening on %s...", addr)
+	// ルーティング: 各コントローラーが自分の API を登録する
+	// 全リクエスト共通: リクエストID → トレース → メトリクス → アクセスログ → panic の回復 → CORS (CORS_ALLOWED_ORIGINS で許可するオリジンを絞れる)
+	// ボディの上限とタイムアウトはルートごと (指定がなければ router.DefaultBodyLimit / DefaultTimeout)
+	router := controller.NewRouter(authMiddleware)
+	router.Use(
+		controller.RequestID,
+		controller.Trace,
+		controller.Metrics,
+		controller.AccessLog,
+		controller.Recover,
+		controller.CORS(controller.CORSConfig{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           10 * time.Minute,
+		}),
+	)
+	router.Register(
+		userController,
+		itemController,
+		categoryController,
+		imageController,
+		txController,
+		messageController,
+		helpController,
+		geminiController,
+		healthController,
+		controller.NewMetricsController(cfg.Metrics.Token),
+	)
 
+	addr := fmt.Sprintf(":%s", cfg.Port)
+	slog.Info("listening", "addr", addr, "env", cfg.Env)
+
+	// WriteTimeout は一番長いルートのタイムアウト (Gemini の2分) より長くしておく
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute, // 画像のアップロードがあるので長め
+		WriteTimeout:      3 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
 	go func() {
-		if err := http.ListenAndServe(addr, nil); err != nil {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal(err)
 		}
 	}()
+	healthController.SetReady(true)
 
 	// 終了待ち
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
-	log.Println("Server shutting down...")
+	slog.Info("server shutting down")
+
+	// 1. まず /readyz を 503 にして、ロードバランサーが新しいリクエストを送らなくなるのを少し待つ
+	healthController.SetReady(false)
+	time.Sleep(time.Duration(cfg.Server.ShutdownDelay))
+
+	// 2. 新しい接続を断り、処理中のリクエスト (購入のトランザクションなど) が終わるのを待つ
+	// Cloud Run は SIGTERM の 10 秒後に強制終了するので、合計がそれより短くなるようにする
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("処理中のリクエストを待ちきれませんでした", "error", err)
+	}
+
+	// 3. リクエストが終わってから外部のクライアントを閉じる (DB は defer で最後に閉じる)
+	if err := geminiController.Close(); err != nil {
+		slog.Warn("Gemini クライアントの終了エラー", "error", err)
+	}
+	// 溜まっているスパンを送り切る
+	// ctx は server.Shutdown が期限まで使い切っていることがあるので、別の期限にする (最後のリクエストのスパンを落とさないように)
+	traceCtx, traceCancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer traceCancel()
+	if err := shutdownTracing(traceCtx); err != nil {
+		slog.Warn("トレースの送信を終えられませんでした", "error", err)
+	}
+	slog.Info("server stopped")
+}
+
+// tracingShutdownTimeout: 終了時にスパンを送り切るのを待つ時間
+// SHUTDOWN_DELAY と SHUTDOWN_TIMEOUT (デフォルト 2 秒 + 7 秒) の後なので、Cloud Run の 10 秒に収まるようにしておく
+const tracingShutdownTimeout = time.Second
+
+// tokenSecret: アクセストークンの署名鍵 (AUTH_TOKEN_SECRET)
+// 未設定ならランダムに作る。その場合、再起動やインスタンス間でトークンが通らなくなる (local 以外では config が必須にしている)
+func tokenSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	slog.Warn("AUTH_TOKEN_SECRET が未設定のため、一時的な署名鍵を使います")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+func newImageStorage(cfg config.Images) (storage.Storage, error) {
+	if cfg.Bucket != "" {
+		return storage.NewGCSStorage(context.Background(), cfg.Bucket)
+	}
+	return storage.NewLocalStorage(cfg.Dir), nil
+}
+
+func auditPasswords(u *usecase.UserUsecase) {
+	users, err := u.PlaintextPasswordUsers(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(users) == 0 {
+		log.Println("平文パスワードのユーザーはいません")
+		return
+	}
+	log.Printf("平文パスワードのユーザーが %d 件あります (次回ログイン時にハッシュ化されます)", len(users))
+	for _, user := range users {
+		fmt.Printf("%d\t%s\n", user.ID, user.Name)
+	}
+}
+
+// runMigrate: `server migrate ...` の中身
+//
+//	migrate up                 未適用をすべて適用
+//	migrate down [n]           新しい方から n 個戻す (デフォルト 1)
+//	migrate status             適用状況を表示
+//	migrate baseline <version> 導入前に手で流していた DB で、version までを適用済みにする
+func runMigrate(dbConn *sql.DB, args []string) error {
+	migrator, err := db.NewMigrator(dbConn, migrations.FS)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("適用: %03d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("スキーマは最新です")
+		}
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("migrate down: invalid step count %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("戻した: %03d_%s", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%03d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	case "baseline":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate baseline <version>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("migrate baseline: invalid version %q", args[1])
+		}
+		return migrator.Baseline(ctx, version)
+	default:
+		return fmt.Errorf("usage: migrate up|down [n]|status|baseline <version>")
+	}
+}
+
+// runSeed: `server seed <セット名>` の中身。入れた商品は検索インデックスにも登録する
+func runSeed(dbConn *sql.DB, hasher *auth.PasswordHasher, items *usecase.ItemUsecase, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: seed <%s>", strings.Join(seed.Sets(), "|"))
+	}
+	ctx := context.Background()
+	result, err := seed.NewSeeder(dbConn, hasher).Run(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	log.Printf("%s を投入しました (追加した行: %s)", args[0], result)
+
+	n, err := items.ReindexSearch(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d 件の商品を検索インデックスに登録しました", n)
+	return nil
 }
@@ -0,0 +1,73 @@
+// Package media はアップロードされた画像の検証と加工を行う
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"db/apperr"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // WebP の読み込み
+)
+
+var ErrUnsupportedImage = apperr.New(apperr.Unsupported, "unsupported_image", "unsupported image type (jpeg, png, webp only)")
+
+// 大きすぎる画像は展開するとメモリを食い尽くすので、デコード前に弾く
+const maxPixels = 40_000_000
+
+const jpegQuality = 90
+
+// Image: 加工済みの画像
+type Image struct {
+	Data        []byte
+	ContentType string
+	Ext         string // ".jpg" など
+	Width       int
+	Height      int
+	Pixels      image.Image // デコード済みの画像 (サイズ違いを作るときに使う)
+}
+
+// Sanitize: 画像をデコードして作り直す
+// 作り直すことで EXIF (位置情報など) のメタデータはすべて消える。向き (Orientation) だけは画素に反映しておく
+// PNG は PNG のまま、JPEG・WebP は JPEG にする
+func Sanitize(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: image is too large (%dx%d)", ErrUnsupportedImage, cfg.Width, cfg.Height)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	out := &Image{Width: img.Bounds().Dx(), Height: img.Bounds().Dy(), Pixels: img}
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+		out.ContentType, out.Ext = "image/png", ".png"
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+		out.ContentType, out.Ext = "image/jpeg", ".jpg"
+	}
+	if err != nil {
+		return nil, err
+	}
+	out.Data = buf.Bytes()
+	return out, nil
+}
@@ -0,0 +1,55 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// withExif: JPEG の SOI の直後に EXIF (APP1) を差し込む
+func withExif(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := append([]byte("Exif\x00\x00"), []byte("GPSLatitude 35.6812")...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestSanitize(t *testing.T) {
+	data := withExif(t, 40, 30)
+	if !bytes.Contains(data, []byte("GPSLatitude")) {
+		t.Fatal("test image has no exif")
+	}
+
+	out, err := Sanitize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out.Data, []byte("Exif")) || bytes.Contains(out.Data, []byte("GPSLatitude")) {
+		t.Error("exif was not removed")
+	}
+	if out.ContentType != "image/jpeg" || out.Ext != ".jpg" {
+		t.Errorf("type = %s %s", out.ContentType, out.Ext)
+	}
+	if out.Width != 40 || out.Height != 30 {
+		t.Errorf("size = %dx%d, want 40x30", out.Width, out.Height)
+	}
+}
+
+func TestSanitize_Unsupported(t *testing.T) {
+	for _, data := range [][]byte{[]byte("GIF89a......"), []byte("<svg></svg>"), {0xFF, 0xD8, 0xFF, 0x00}} {
+		if _, err := Sanitize(data); !errors.Is(err, ErrUnsupportedImage) {
+			t.Errorf("Sanitize(%q) err = %v, want ErrUnsupportedImage", data, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/disintegration/imaging"
+)
+
+// 縮小版の JPEG は少し品質を落としてもよい
+const variantJPEGQuality = 82
+
+// Encoded: エンコード済みの画像1つ
+type Encoded struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// Resize: 長い辺が maxSide 以下になるように縮小する (小さい画像は拡大しない)
+func Resize(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= maxSide && b.Dy() <= maxSide {
+		return img
+	}
+	return imaging.Fit(img, maxSide, maxSide, imaging.Lanczos)
+}
+
+// EncodeVariants: どのブラウザでも表示できる形式 (JPEG、透過があれば PNG) と WebP の2つにエンコードする
+// WebP は可逆なので、写真だと JPEG より大きくなることもある (どちらを返すかは配信時に決める)
+func EncodeVariants(img image.Image) ([]Encoded, error) {
+	b := img.Bounds()
+	fallback := Encoded{Width: b.Dx(), Height: b.Dy()}
+	var buf bytes.Buffer
+	if opaque(img) {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: variantJPEGQuality}); err != nil {
+			return nil, err
+		}
+		fallback.ContentType, fallback.Ext = "image/jpeg", ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		fallback.ContentType, fallback.Ext = "image/png", ".png"
+	}
+	fallback.Data = buf.Bytes()
+
+	data, err := EncodeWebP(img)
+	if err != nil {
+		return nil, err
+	}
+	webp := Encoded{Data: data, ContentType: "image/webp", Ext: ".webp", Width: b.Dx(), Height: b.Dy()}
+	return []Encoded{fallback, webp}, nil
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
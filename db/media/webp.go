@@ -0,0 +1,445 @@
+package media
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"math/bits"
+)
+
+// 可逆 (lossless, VP8L) WebP のエンコーダー
+// 標準ライブラリにも x/image にも WebP のエンコーダーは無いので、必要な部分だけ実装している
+// 使っているのは 減算グリーン変換 + 予測変換 + ハフマン符号 だけ (LZ77 とカラーキャッシュは使わない)
+// 仕様: https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification
+
+const (
+	webpMaxSize = 16384
+
+	// 予測モードを切り替えるブロックの大きさ (1<<4 = 16px 四方)
+	predictorBits = 4
+
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 7
+)
+
+// 予測モード (仕様の番号)。ここでは単純な3つから選ぶ
+const (
+	predictLeft    = 1
+	predictTop     = 2
+	predictAverage = 7 // Average2(L, T)
+)
+
+// コード長のコード長を書く順番 (仕様で決まっている)
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// EncodeWebP: 画像を可逆 WebP にする
+func EncodeWebP(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > webpMaxSize || height > webpMaxSize {
+		return nil, errors.New("webp: invalid image size")
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	argb := make([]uint32, width*height)
+	hasAlpha := false
+	for i := range argb {
+		p := nrgba.Pix[i*4 : i*4+4]
+		argb[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		if p[3] != 0xff {
+			hasAlpha = true
+		}
+	}
+
+	w := &bitWriter{}
+	w.write(0x2f, 8) // シグネチャ
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	if hasAlpha {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+	w.write(0, 3) // バージョン
+
+	// 変換は書いた順に適用し、デコーダーは逆順に戻す
+	// 1. 減算グリーン変換: 赤と青から緑を引く (色の相関を減らす)
+	w.write(1, 1)
+	w.write(2, 2)
+	subtractGreen(argb)
+
+	// 2. 予測変換: ブロックごとに予測モードを選び、予測との差分だけを残す
+	w.write(1, 1)
+	w.write(0, 2)
+	w.write(predictorBits-2, 3)
+	modes, residuals := predict(argb, width, height)
+	writeEntropyImage(w, modes, false)
+
+	w.write(0, 1) // 変換はここまで
+	writeEntropyImage(w, residuals, true)
+
+	data := w.bytes()
+	padded := len(data) + len(data)&1
+	out := make([]byte, 0, 20+padded)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(4+8+padded))
+	out = append(out, "WEBPVP8L"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)&1 == 1 {
+		out = append(out, 0)
+	}
+	return out, nil
+}
+
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := (p >> 8) & 0xff
+		r := ((p >> 16) - g) & 0xff
+		b := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+}
+
+// predict: ブロックごとに差分が一番小さくなる予測モードを選び、
+// 予測モードの画像 (緑チャンネルにモード) と差分の画像を返す
+func predict(argb []uint32, width, height int) (modes, residuals []uint32) {
+	tilesX := (width + 1<<predictorBits - 1) >> predictorBits
+	tilesY := (height + 1<<predictorBits - 1) >> predictorBits
+	modes = make([]uint32, tilesX*tilesY)
+	residuals = make([]uint32, len(argb))
+
+	for ty := range tilesY {
+		for tx := range tilesX {
+			x0, y0 := tx<<predictorBits, ty<<predictorBits
+			x1, y1 := min(x0+1<<predictorBits, width), min(y0+1<<predictorBits, height)
+
+			best, bestCost := predictLeft, -1
+			for _, mode := range []int{predictLeft, predictTop, predictAverage} {
+				cost := 0
+				for y := y0; y < y1; y++ {
+					for x := x0; x < x1; x++ {
+						cost += residualCost(subPixels(argb[y*width+x], prediction(argb, width, x, y, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = 0xff000000 | uint32(best)<<8
+
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					i := y*width + x
+					residuals[i] = subPixels(argb[i], prediction(argb, width, x, y, best))
+				}
+			}
+		}
+	}
+	return modes, residuals
+}
+
+// prediction: (x, y) の予測値。左上・1行目・1列目はモードに関係なく仕様で決まっている
+func prediction(argb []uint32, width, x, y, mode int) uint32 {
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return argb[y*width+x-1]
+	case x == 0:
+		return argb[(y-1)*width+x]
+	}
+	left, top := argb[y*width+x-1], argb[(y-1)*width+x]
+	switch mode {
+	case predictLeft:
+		return left
+	case predictTop:
+		return top
+	default:
+		return average2(left, top)
+	}
+}
+
+// average2: チャンネルごとの平均 (切り捨て)
+func average2(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+// subPixels: チャンネルごとの引き算 (256 で割った余り)
+func subPixels(a, b uint32) uint32 {
+	alphaGreen := 0x00ff00ff + (a & 0xff00ff00) - (b & 0xff00ff00)
+	redBlue := 0xff00ff00 + (a & 0x00ff00ff) - (b & 0x00ff00ff)
+	return alphaGreen&0xff00ff00 | redBlue&0x00ff00ff
+}
+
+// residualCost: 差分の大きさの目安 (各チャンネルを符号付きとみなした絶対値の和)
+func residualCost(p uint32) int {
+	cost := 0
+	for shift := 0; shift < 32; shift += 8 {
+		v := int(p>>shift) & 0xff
+		cost += min(v, 256-v)
+	}
+	return cost
+}
+
+// writeEntropyImage: ピクセルをハフマン符号で書く (全体で1組の符号を使う)
+// topLevel はメインの画像かどうか (変換用の小さい画像には meta prefix のビットが無い)
+func writeEntropyImage(w *bitWriter, pixels []uint32, topLevel bool) {
+	w.write(0, 1) // カラーキャッシュ無し
+	if topLevel {
+		w.write(0, 1) // meta prefix codes 無し
+	}
+
+	// 緑の符号には LZ77 の長さの分 (24個) も含まれる。距離の符号は使わないが書く必要がある
+	var green [256 + 24]int
+	var red, blue, alpha [256]int
+	var distance [40]int
+	for _, p := range pixels {
+		alpha[p>>24]++
+		red[(p>>16)&0xff]++
+		green[(p>>8)&0xff]++
+		blue[p&0xff]++
+	}
+	codes := [5]*prefixCode{
+		newPrefixCode(green[:], maxCodeLength),
+		newPrefixCode(red[:], maxCodeLength),
+		newPrefixCode(blue[:], maxCodeLength),
+		newPrefixCode(alpha[:], maxCodeLength),
+		newPrefixCode(distance[:], maxCodeLength),
+	}
+	for _, c := range codes {
+		c.writeTo(w)
+	}
+
+	for _, p := range pixels {
+		codes[0].writeSymbol(w, int(p>>8)&0xff)
+		codes[1].writeSymbol(w, int(p>>16)&0xff)
+		codes[2].writeSymbol(w, int(p)&0xff)
+		codes[3].writeSymbol(w, int(p>>24))
+	}
+}
+
+// prefixCode: ハフマン符号 (正準符号)
+type prefixCode struct {
+	lengths []uint8  // 宣言する符号長 (0 は使わない記号)
+	nbits   []uint8  // 実際に書くビット数 (記号が1つだけのときは 0 ビット)
+	codes   []uint16 // 書き込み順 (LSB から) に並べ替えた符号
+	used    []int    // 使われている記号
+}
+
+func newPrefixCode(hist []int, maxLength int) *prefixCode {
+	c := &prefixCode{
+		lengths: make([]uint8, len(hist)),
+		nbits:   make([]uint8, len(hist)),
+		codes:   make([]uint16, len(hist)),
+	}
+	for s, n := range hist {
+		if n > 0 {
+			c.used = append(c.used, s)
+		}
+	}
+	switch len(c.used) {
+	case 0:
+		// 使われない符号も何か宣言しておく必要がある
+		c.used = []int{0}
+		c.lengths[0] = 1
+		return c
+	case 1:
+		c.lengths[c.used[0]] = 1
+		return c
+	}
+
+	huffmanLengths(hist, c.lengths, maxLength)
+
+	// 正準ハフマン符号を割り当てる (deflate と同じ)
+	var count [maxCodeLength + 1]int
+	for _, l := range c.lengths {
+		if l > 0 {
+			count[l]++
+		}
+	}
+	var next [maxCodeLength + 2]int
+	code := 0
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range c.lengths {
+		if l > 0 {
+			c.codes[s] = uint16(bits.Reverse16(uint16(next[l])) >> (16 - l))
+			c.nbits[s] = l
+			next[l]++
+		}
+	}
+	return c
+}
+
+func (c *prefixCode) writeSymbol(w *bitWriter, s int) {
+	w.write(uint32(c.codes[s]), uint(c.nbits[s]))
+}
+
+// writeTo: 符号の定義を書く
+func (c *prefixCode) writeTo(w *bitWriter) {
+	// 記号が2つ以下で 256 未満なら簡易形式で書ける
+	if len(c.used) <= 2 && c.used[len(c.used)-1] < 256 {
+		w.write(1, 1)
+		w.write(uint32(len(c.used)-1), 1)
+		if c.used[0] <= 1 {
+			w.write(0, 1)
+			w.write(uint32(c.used[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(c.used[0]), 8)
+		}
+		if len(c.used) == 2 {
+			w.write(uint32(c.used[1]), 8)
+		}
+		return
+	}
+
+	w.write(0, 1)
+	// 符号長の列を、0 の連続だけ 17 / 18 でまとめた記号列にする
+	type token struct{ sym, extra int }
+	var tokens []token
+	var hist [19]int
+	for i := 0; i < len(c.lengths); {
+		if c.lengths[i] != 0 {
+			tokens = append(tokens, token{sym: int(c.lengths[i])})
+			hist[c.lengths[i]]++
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(c.lengths) && c.lengths[i+run] == 0 && run < 138 {
+			run++
+		}
+		switch {
+		case run >= 11:
+			tokens = append(tokens, token{sym: 18, extra: run - 11})
+			hist[18]++
+		case run >= 3:
+			tokens = append(tokens, token{sym: 17, extra: run - 3})
+			hist[17]++
+		default:
+			run = 1
+			tokens = append(tokens, token{sym: 0})
+			hist[0]++
+		}
+		i += run
+	}
+
+	lengthCode := newPrefixCode(hist[:], maxCodeLengthCodeLength)
+	n := len(codeLengthCodeOrder)
+	for n > 4 && lengthCode.lengths[codeLengthCodeOrder[n-1]] == 0 {
+		n--
+	}
+	w.write(uint32(n-4), 4)
+	for _, s := range codeLengthCodeOrder[:n] {
+		w.write(uint32(lengthCode.lengths[s]), 3)
+	}
+
+	w.write(0, 1) // max_symbol は使わない (全記号の符号長を書く)
+	for _, t := range tokens {
+		lengthCode.writeSymbol(w, t.sym)
+		switch t.sym {
+		case 17:
+			w.write(uint32(t.extra), 3)
+		case 18:
+			w.write(uint32(t.extra), 7)
+		}
+	}
+}
+
+// huffmanLengths: ハフマン符号の符号長を lengths に入れる (記号は2つ以上)
+// maxLength を超えたら、少ない記号の出現数を底上げして作り直す
+func huffmanLengths(hist []int, lengths []uint8, maxLength int) {
+	for minCount := 1; ; minCount *= 2 {
+		h := &nodeHeap{}
+		for s, n := range hist {
+			if n > 0 {
+				h.nodes = append(h.nodes, huffmanNode{count: max(n, minCount), symbol: s, left: -1, right: -1})
+				h.order = append(h.order, len(h.nodes)-1)
+			}
+		}
+		heap.Init(h)
+		for h.Len() > 1 {
+			a := heap.Pop(h).(int)
+			b := heap.Pop(h).(int)
+			h.nodes = append(h.nodes, huffmanNode{count: h.nodes[a].count + h.nodes[b].count, symbol: -1, left: a, right: b})
+			heap.Push(h, len(h.nodes)-1)
+		}
+
+		depth := 0
+		var walk func(n, d int)
+		walk = func(n, d int) {
+			node := h.nodes[n]
+			if node.symbol >= 0 {
+				lengths[node.symbol] = uint8(d)
+				depth = max(depth, d)
+				return
+			}
+			walk(node.left, d+1)
+			walk(node.right, d+1)
+		}
+		walk(h.order[0], 0)
+		if depth <= maxLength {
+			return
+		}
+	}
+}
+
+type huffmanNode struct {
+	count       int
+	symbol      int // 葉でなければ -1
+	left, right int
+}
+
+// nodeHeap: 出現数の少ない順に取り出すヒープ (order に nodes の添字を持つ)
+type nodeHeap struct {
+	nodes []huffmanNode
+	order []int
+}
+
+func (h *nodeHeap) Len() int { return len(h.order) }
+func (h *nodeHeap) Less(i, j int) bool {
+	a, b := h.nodes[h.order[i]], h.nodes[h.order[j]]
+	if a.count != b.count {
+		return a.count < b.count
+	}
+	return h.order[i] < h.order[j]
+}
+func (h *nodeHeap) Swap(i, j int) { h.order[i], h.order[j] = h.order[j], h.order[i] }
+func (h *nodeHeap) Push(x any)    { h.order = append(h.order, x.(int)) }
+func (h *nodeHeap) Pop() any {
+	n := h.order[len(h.order)-1]
+	h.order = h.order[:len(h.order)-1]
+	return n
+}
+
+// bitWriter: LSB から詰めていくビット列
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.acc |= uint64(v) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
@@ -0,0 +1,98 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	tests := map[string]image.Image{
+		"1px":    solid(1, 1, color.NRGBA{R: 10, G: 20, B: 30, A: 255}),
+		"solid":  solid(33, 17, color.NRGBA{R: 200, G: 100, B: 50, A: 255}),
+		"noise":  noise(70, 45, false),
+		"alpha":  noise(20, 31, true),
+		"stripe": stripes(64, 64),
+	}
+	for name, img := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := EncodeWebP(img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := webp.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.Bounds() != img.Bounds() {
+				t.Fatalf("bounds = %v, want %v", got.Bounds(), img.Bounds())
+			}
+			// 可逆なので、すべてのピクセルが元と一致するはず
+			for y := range img.Bounds().Dy() {
+				for x := range img.Bounds().Dx() {
+					want := color.NRGBAModel.Convert(img.At(x, y))
+					if c := color.NRGBAModel.Convert(got.At(x, y)); c != want {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, c, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func solid(w, h int, c color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func noise(w, h int, alpha bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] = byte(seed >> 24)
+		if i%4 == 3 && !alpha {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img
+}
+
+func stripes(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8((x + y) * 2), A: 255})
+		}
+	}
+	return img
+}
+
+func TestHuffmanLengths_Limit(t *testing.T) {
+	// フィボナッチ数列の出現数だと、制限しなければ符号長が記号数近くまで伸びる
+	hist := make([]int, 30)
+	a, b := 1, 1
+	for i := range hist {
+		hist[i] = a
+		a, b = b, a+b
+	}
+	lengths := make([]uint8, len(hist))
+	huffmanLengths(hist, lengths, maxCodeLength)
+
+	kraft := 0.0
+	for _, l := range lengths {
+		if l == 0 || l > maxCodeLength {
+			t.Fatalf("length = %d", l)
+		}
+		kraft += 1 / float64(uint(1)<<l)
+	}
+	if kraft != 1 {
+		t.Errorf("code is not complete (kraft sum = %v)", kraft)
+	}
+}
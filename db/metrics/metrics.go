@@ -0,0 +1,86 @@
+// Package metrics は Prometheus のメトリクス (GET /metrics で公開する)
+//
+// どれもプロセスに1つなので、パッケージ変数にしてデフォルトのレジストリに登録しておく
+// (Go ランタイムとプロセスのメトリクスもデフォルトで付いてくる)
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HTTP: route は "GET /api/items/{id}" のようなパターン (どれにも当たらなければ "unmatched")
+// パスそのままだと ID ごとに系列ができてしまうので使わない
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+)
+
+// 購入の結果 (TransactionDao.Purchase)
+const (
+	PurchaseSuccess  = "success"
+	PurchaseConflict = "conflict"  // 行ロックを取った時点で買えなかった (売り切れ・取引中・自分の商品など)
+	PurchaseNotFound = "not_found" // 商品がない
+	PurchaseError    = "error"     // DB のエラー
+)
+
+var Purchases = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "purchases_total",
+	Help: "Purchase attempts by result (success, conflict, not_found, error).",
+}, []string{"result"})
+
+// AI の呼び出し: backend は "gemini" / "discovery_engine"、endpoint は呼び出し元の機能
+var (
+	AIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ai_request_duration_seconds",
+		Help:    "Latency of outbound AI requests.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"backend", "endpoint"})
+
+	AIFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_request_failures_total",
+		Help: "Failed outbound AI requests.",
+	}, []string{"backend", "endpoint"})
+
+	AITokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_tokens_total",
+		Help: "Tokens used by AI requests (kind is prompt or output).",
+	}, []string{"backend", "endpoint", "kind"})
+)
+
+// ObserveAI: start からの時間を記録し、err があれば失敗も数える
+func ObserveAI(backend, endpoint string, start time.Time, err error) {
+	AIRequestDuration.WithLabelValues(backend, endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		AIFailures.WithLabelValues(backend, endpoint).Inc()
+	}
+}
+
+// RegisterDB: コネクションプールの状態 (open / in_use / wait_count / wait_duration など) を公開する
+func RegisterDB(name string, db *sql.DB) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler: /metrics のハンドラー
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,53 @@
+-- 最初からあるテーブル (マイグレーションを導入する前の形)
+-- 既存の DB ではすでにあるので IF NOT EXISTS にしている
+
+CREATE TABLE IF NOT EXISTS users (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    password   VARCHAR(255) NOT NULL,
+    created_at DATETIME     NULL
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id   INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS items (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    category_id INT          NOT NULL,
+    price       INT          NOT NULL,
+    description TEXT         NOT NULL,
+    status      VARCHAR(20)  NOT NULL DEFAULT 'ON_SALE',
+    seller_id   INT          NOT NULL,
+    image_name  VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    item_id    INT      NOT NULL,
+    buyer_id   INT      NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    item_id     INT      NOT NULL,
+    sender_id   INT      NOT NULL,
+    receiver_id INT      NOT NULL,
+    content     TEXT     NOT NULL,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ログインのセッション (リフレッシュトークンのハッシュ)。トークン認証を入れたときに追加した
+CREATE TABLE IF NOT EXISTS sessions (
+    id                 VARCHAR(32) PRIMARY KEY,
+    user_id            INT         NOT NULL,
+    refresh_token_hash CHAR(64)    NOT NULL,
+    expires_at         DATETIME    NOT NULL,
+    revoked_at         DATETIME    NULL,
+    created_at         DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_sessions_refresh_token_hash (refresh_token_hash),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS user_identities;
+
+ALTER TABLE users
+    DROP INDEX uq_users_email,
+    DROP COLUMN email,
+    DROP COLUMN email_verified,
+    DROP COLUMN display_name,
+    DROP COLUMN avatar_url,
+    DROP COLUMN bio,
+    DROP COLUMN updated_at,
+    MODIFY COLUMN created_at DATETIME NULL;
@@ -0,0 +1,39 @@
+-- ユーザープロフィールと外部ID(ソーシャルログイン)の紐付け
+
+-- created_at を NOT NULL にするので、先に NULL の行を埋めておく (NULL が残っていると ALTER が失敗する)
+UPDATE users SET created_at = NOW() WHERE created_at IS NULL;
+
+ALTER TABLE users
+    ADD COLUMN email          VARCHAR(255) NULL,
+    ADD COLUMN email_verified BOOLEAN      NOT NULL DEFAULT FALSE,
+    ADD COLUMN display_name   VARCHAR(50)  NOT NULL DEFAULT '',
+    ADD COLUMN avatar_url     VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN bio            TEXT         NULL,
+    ADD COLUMN updated_at     DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    MODIFY COLUMN created_at  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD UNIQUE KEY uq_users_email (email);
+
+-- 既存のソーシャルログインユーザー: name にメアドが入っているので email に移す
+-- (Google で確認済みのメアドなので email_verified = TRUE)
+UPDATE users
+SET email          = name,
+    email_verified = TRUE,
+    display_name   = LEFT(SUBSTRING_INDEX(name, '@', 1), 50),
+    password       = ''
+WHERE password = 'google_login' OR (password = '' AND name LIKE '%@%');
+
+-- パスワードユーザー: 表示名はログイン名と同じにしておく
+-- (name は 255 文字まで入るが display_name は 50 文字までなので切り詰める)
+UPDATE users SET display_name = LEFT(name, 50) WHERE display_name = '';
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT          NOT NULL,
+    provider   VARCHAR(32)  NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    email      VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_user_identities_provider_subject (provider, subject),
+    KEY idx_user_identities_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE items
+    DROP KEY idx_items_category_id,
+    DROP COLUMN created_at,
+    DROP COLUMN updated_at;
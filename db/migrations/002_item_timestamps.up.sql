@@ -0,0 +1,6 @@
+-- 商品詳細で出品日時・更新日時を返すためのカラム
+
+ALTER TABLE items
+    ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    ADD KEY idx_items_category_id (category_id);
@@ -0,0 +1,8 @@
+ALTER TABLE items
+    DROP KEY idx_items_created_at_id,
+    DROP KEY idx_items_price_id,
+    DROP KEY idx_items_status,
+    DROP KEY idx_items_seller_id;
+
+ALTER TABLE messages
+    DROP KEY idx_messages_item_id;
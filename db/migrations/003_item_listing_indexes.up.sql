@@ -0,0 +1,10 @@
+-- 商品一覧の並び替え・絞り込み用インデックス
+
+ALTER TABLE items
+    ADD KEY idx_items_created_at_id (created_at, id),
+    ADD KEY idx_items_price_id (price, id),
+    ADD KEY idx_items_status (status),
+    ADD KEY idx_items_seller_id (seller_id);
+
+ALTER TABLE messages
+    ADD KEY idx_messages_item_id (item_id);
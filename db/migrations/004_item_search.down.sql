@@ -0,0 +1,5 @@
+ALTER TABLE items
+    DROP KEY ft_items_search_name,
+    DROP KEY ft_items_search,
+    DROP COLUMN search_name,
+    DROP COLUMN search_body;
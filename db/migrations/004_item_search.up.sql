@@ -0,0 +1,10 @@
+-- 商品検索用の正規化済みカラムと FULLTEXT インデックス (ngram パーサー)
+-- 適用後、`server reindex-search` で既存の商品の検索用カラムを埋めること
+
+ALTER TABLE items
+    ADD COLUMN search_name VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN search_body TEXT         NULL;
+
+-- InnoDB は FULLTEXT インデックスを1文で1つずつしか作れない (ERROR 1795) ので分ける
+ALTER TABLE items ADD FULLTEXT KEY ft_items_search_name (search_name) WITH PARSER ngram;
+ALTER TABLE items ADD FULLTEXT KEY ft_items_search (search_name, search_body) WITH PARSER ngram;
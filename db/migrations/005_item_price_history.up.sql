@@ -0,0 +1,11 @@
+-- 商品の価格変更履歴
+
+CREATE TABLE IF NOT EXISTS item_price_history (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    item_id    INT      NOT NULL,
+    old_price  INT      NOT NULL,
+    new_price  INT      NOT NULL,
+    changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_item_price_history_item_id (item_id),
+    FOREIGN KEY (item_id) REFERENCES items (id)
+);
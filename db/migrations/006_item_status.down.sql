@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS item_status_history;
+
+-- 元のステータス (ON_SALE / SOLD_OUT / WITHDRAWN) に寄せる
+UPDATE items SET status = 'SOLD_OUT' WHERE status IN ('TRADING', 'SHIPPED', 'COMPLETED');
+UPDATE items SET status = 'ON_SALE' WHERE status IN ('RESERVED', 'CANCELLED');
+UPDATE items SET status = 'WITHDRAWN' WHERE status = 'DRAFT';
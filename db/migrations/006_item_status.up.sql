@@ -0,0 +1,15 @@
+-- 商品ステータスの遷移履歴と、旧ステータス (SOLD_OUT) の置き換え
+
+CREATE TABLE IF NOT EXISTS item_status_history (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    item_id     INT         NOT NULL,
+    from_status VARCHAR(20) NULL, -- 出品時は NULL
+    to_status   VARCHAR(20) NOT NULL,
+    actor_id    INT         NOT NULL,
+    changed_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_item_status_history_item_id (item_id),
+    FOREIGN KEY (item_id) REFERENCES items (id)
+);
+
+-- 売り切れは「取引中」として扱う (発送・受け取りの状態は記録がないので分からない)
+UPDATE items SET status = 'TRADING' WHERE status = 'SOLD_OUT';
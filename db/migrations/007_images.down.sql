@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS item_images;
+DROP TABLE IF EXISTS images;
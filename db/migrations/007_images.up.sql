@@ -0,0 +1,24 @@
+-- アップロードされた画像と、商品との紐付け (表示順つき)
+
+CREATE TABLE IF NOT EXISTS images (
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    owner_id     INT          NOT NULL,
+    storage_key  VARCHAR(255) NOT NULL, -- 中身の SHA-256 から作ったキー
+    content_type VARCHAR(50)  NOT NULL,
+    width        INT          NOT NULL,
+    height       INT          NOT NULL,
+    size         INT          NOT NULL,
+    created_at   DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_images_owner_id (owner_id),
+    FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS item_images (
+    item_id  INT NOT NULL,
+    image_id INT NOT NULL,
+    position INT NOT NULL, -- 0 が1枚目 (一覧に出る画像)
+    PRIMARY KEY (item_id, position),
+    KEY idx_item_images_image_id (image_id),
+    FOREIGN KEY (item_id) REFERENCES items (id),
+    FOREIGN KEY (image_id) REFERENCES images (id)
+);
@@ -0,0 +1,4 @@
+UPDATE items SET image_name = REPLACE(image_name, '?size=thumb', '')
+WHERE image_name LIKE '/api/images/%?size=thumb';
+
+DROP TABLE IF EXISTS image_variants;
@@ -0,0 +1,17 @@
+-- 画像のサイズ違い (thumb / medium / full) と形式違い (JPEG か PNG と WebP)
+
+CREATE TABLE IF NOT EXISTS image_variants (
+    image_id     INT          NOT NULL,
+    size         VARCHAR(20)  NOT NULL,
+    content_type VARCHAR(50)  NOT NULL,
+    storage_key  VARCHAR(255) NOT NULL,
+    width        INT          NOT NULL,
+    height       INT          NOT NULL,
+    bytes        INT          NOT NULL,
+    PRIMARY KEY (image_id, size, content_type),
+    FOREIGN KEY (image_id) REFERENCES images (id)
+);
+
+-- 一覧に出す画像をサムネイルにする
+UPDATE items SET image_name = CONCAT(image_name, '?size=thumb')
+WHERE image_name LIKE '/api/images/%' AND image_name NOT LIKE '%?%';
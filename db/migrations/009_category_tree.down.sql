@@ -0,0 +1,8 @@
+ALTER TABLE users
+    DROP COLUMN is_admin;
+
+ALTER TABLE categories
+    DROP FOREIGN KEY fk_categories_parent,
+    DROP KEY idx_categories_parent_id,
+    DROP COLUMN parent_id,
+    DROP COLUMN sort_order;
@@ -0,0 +1,12 @@
+-- カテゴリの親子関係と表示順、管理者フラグ
+
+ALTER TABLE categories
+    ADD COLUMN parent_id  INT NULL AFTER name, -- 一番上のカテゴリは NULL
+    ADD COLUMN sort_order INT NOT NULL DEFAULT 0 AFTER parent_id,
+    ADD KEY idx_categories_parent_id (parent_id),
+    ADD CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories (id);
+
+-- カテゴリ管理 (POST/PATCH/DELETE /api/categories) ができるユーザー
+-- 例: UPDATE users SET is_admin = TRUE WHERE id = 1;
+ALTER TABLE users
+    ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE AFTER bio;
@@ -0,0 +1,3 @@
+ALTER TABLE items
+    DROP KEY idx_items_message_count_id,
+    DROP COLUMN message_count;
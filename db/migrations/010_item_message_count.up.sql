@@ -0,0 +1,10 @@
+-- 人気順 (問い合わせが多い順) の並び替えキー
+-- 一覧のたびに messages を数えると、ページの途中で数が変わってカーソルがずれるうえ、候補の行ごとにサブクエリが走るので
+-- items に数を持たせてインデックスを張る (メッセージの保存時に MessageDao が増やす)
+
+ALTER TABLE items
+    ADD COLUMN message_count INT NOT NULL DEFAULT 0,
+    ADD KEY idx_items_message_count_id (message_count, id);
+
+UPDATE items i
+SET message_count = (SELECT COUNT(*) FROM messages m WHERE m.item_id = i.id);
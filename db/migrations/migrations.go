@@ -0,0 +1,9 @@
+// Package migrations はスキーマのマイグレーション (SQL) をバイナリに埋め込む
+// ファイル名は NNN_名前.up.sql / NNN_名前.down.sql (NNN がバージョン)
+// 適用は `server migrate up` で行う
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,16 @@
+package model
+
+// Category: 商品カテゴリ (親子の木構造)
+// 商品は子カテゴリを持たないカテゴリ (葉) にだけ登録できる
+type Category struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ParentID  *int   `json:"parent_id"` // 一番上のカテゴリは null
+	SortOrder int    `json:"sort_order"`
+}
+
+// CategoryNode: GET /api/categories で返す木の1ノード
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Image: アップロードされた画像
+// 保存先のキーは中身の SHA-256 から作る (同じ画像は同じキーになる)
+type Image struct {
+	ID          int            `json:"id"`
+	OwnerID     int            `json:"owner_id"`
+	StorageKey  string         `json:"-"`
+	ContentType string         `json:"content_type"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Size        int            `json:"size"` // バイト数
+	URL         string         `json:"url"`
+	Variants    []ImageVariant `json:"variants,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// ImageSize: 配信する画像の大きさ
+type ImageSize string
+
+const (
+	ImageSizeThumb  ImageSize = "thumb"  // 一覧のカード用
+	ImageSizeMedium ImageSize = "medium" // 商品詳細用
+	ImageSizeFull   ImageSize = "full"   // 拡大表示用
+)
+
+// ImageSizes: アップロード時に作るサイズ (小さい順)
+var ImageSizes = []ImageSize{ImageSizeThumb, ImageSizeMedium, ImageSizeFull}
+
+// MaxSide: 長い辺の最大ピクセル数 (知らないサイズなら 0)
+func (s ImageSize) MaxSide() int {
+	switch s {
+	case ImageSizeThumb:
+		return 240
+	case ImageSizeMedium:
+		return 640
+	case ImageSizeFull:
+		return 1600
+	}
+	return 0
+}
+
+// ImageVariant: サイズ違い・形式違いの画像 (1つのサイズに JPEG か PNG と WebP がある)
+type ImageVariant struct {
+	Size        ImageSize `json:"size"`
+	ContentType string    `json:"content_type"`
+	StorageKey  string    `json:"-"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Bytes       int       `json:"bytes"`
+}
+
+// ImageURL: 画像を配信する URL (GET /api/images/{id})
+func ImageURL(id int) string {
+	return fmt.Sprintf("/api/images/%d", id)
+}
+
+// ImageVariantURL: サイズを指定した URL (GET /api/images/{id}?size=thumb)
+func ImageVariantURL(id int, size ImageSize) string {
+	return fmt.Sprintf("/api/images/%d?size=%s", id, size)
+}
@@ -1,13 +1,47 @@
 package model
 
+import "time"
+
 type Item struct {
-	ID           int    `json:"id"`
-	SellerID     int    `json:"seller_id"`
-	CategoryID   int    `json:"category_id"`
-	CategoryName string `json:"category_name"` // JOINして取得したカテゴリ名
-	Name         string `json:"name"`
-	Price        int    `json:"price"`
-	Description  string `json:"description"`
-	ImageName    string `json:"image_name"`
-	Status       string `json:"status"` // "ON_SALE" など
+	ID           int        `json:"id"`
+	SellerID     int        `json:"seller_id"`
+	CategoryID   int        `json:"category_id"`
+	CategoryName string     `json:"category_name"` // JOINして取得したカテゴリ名
+	Name         string     `json:"name"`
+	Price        int        `json:"price"`
+	Description  string     `json:"description"`
+	ImageName    string     `json:"image_name"` // 1枚目の画像の URL (一覧のサムネイル用)
+	Status       ItemStatus `json:"status"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// 出品・編集時に紐付ける画像 (表示順)。編集時に nil なら画像は変えない
+	ImageIDs []int `json:"-"`
+}
+
+// SellerSummary: 商品詳細に載せる出品者の情報
+type SellerSummary struct {
+	ID          int      `json:"id"`
+	DisplayName string   `json:"display_name"`
+	AvatarURL   string   `json:"avatar_url"`
+	Rating      *float64 `json:"rating"`      // 評価機能ができるまでは常に null
+	SalesCount  int      `json:"sales_count"` // 売れた商品の数
+}
+
+// PriceChange: 価格変更の履歴1件
+type PriceChange struct {
+	OldPrice  int       `json:"old_price"`
+	NewPrice  int       `json:"new_price"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ItemDetail: 商品詳細ページ用 (商品本体 + 出品者 + 同じカテゴリの商品)
+type ItemDetail struct {
+	Item
+	Images       []Image       `json:"images"` // 表示順
+	Seller       SellerSummary `json:"seller"`
+	RelatedItems []Item        `json:"related_items"`
+	PriceHistory []PriceChange `json:"price_history"` // 古い順
+	// ステータス変更の履歴 (古い順)。出品者本人が見るときだけ
+	StatusHistory []ItemStatusChange `json:"status_history,omitempty"`
 }
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// 商品一覧の並び順
+const (
+	ItemSortNewest    = "newest"
+	ItemSortPriceAsc  = "price_asc"
+	ItemSortPriceDesc = "price_desc"
+	ItemSortPopular   = "popular" // 問い合わせ(メッセージ)が多い順
+)
+
+// ItemQuery: 商品一覧の絞り込み・並び順・ページング条件 (0 や空文字は「指定なし」)
+type ItemQuery struct {
+	CategoryIDs []int // 指定したカテゴリとその子孫 (空なら全カテゴリ)
+	Status      ItemStatus
+	MinPrice    int
+	MaxPrice    int
+	SellerID    int
+	// 下書き・取り下げた商品も含める (出品者本人が自分の商品一覧を見るとき)
+	IncludeHidden bool
+	Sort          string
+	After         *ItemCursor // 前のページの最後の商品。nil なら先頭から
+	Limit         int
+}
+
+// ItemCursor: キーセットページングの位置 (並び替えキー + ID)
+// 途中に新しい商品が追加されても、続きのページがずれない
+type ItemCursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"t,omitempty"` // newest のとき
+	Value     int       `json:"v,omitempty"` // 価格順・人気順のとき
+	ID        int       `json:"id"`
+}
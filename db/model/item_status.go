@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// ItemStatus: 商品のステータス
+// どのステータスからどこへ移れるかは usecase の遷移表で決める
+type ItemStatus string
+
+const (
+	ItemStatusDraft     ItemStatus = "DRAFT"     // 下書き (出品者本人にだけ見える)
+	ItemStatusOnSale    ItemStatus = "ON_SALE"   // 販売中
+	ItemStatusReserved  ItemStatus = "RESERVED"  // 出品者が取り置き中
+	ItemStatusTrading   ItemStatus = "TRADING"   // 購入され、取引中 (発送待ち)
+	ItemStatusShipped   ItemStatus = "SHIPPED"   // 発送済み (受け取り待ち)
+	ItemStatusCompleted ItemStatus = "COMPLETED" // 受け取り済み、取引完了
+	ItemStatusCancelled ItemStatus = "CANCELLED" // 取引キャンセル
+	ItemStatusWithdrawn ItemStatus = "WITHDRAWN" // 出品者が取り下げた (出品者本人にだけ見える)
+)
+
+var itemStatuses = map[ItemStatus]bool{
+	ItemStatusDraft: true, ItemStatusOnSale: true, ItemStatusReserved: true, ItemStatusTrading: true,
+	ItemStatusShipped: true, ItemStatusCompleted: true, ItemStatusCancelled: true, ItemStatusWithdrawn: true,
+}
+
+func (s ItemStatus) Valid() bool {
+	return itemStatuses[s]
+}
+
+// Hidden: 出品者本人以外には見せないステータスか (一覧・検索・詳細から消える)
+func (s ItemStatus) Hidden() bool {
+	return s == ItemStatusDraft || s == ItemStatusWithdrawn
+}
+
+// Editable: 出品内容(価格・説明など)を編集できるステータスか
+func (s ItemStatus) Editable() bool {
+	return s == ItemStatusDraft || s == ItemStatusOnSale
+}
+
+// ItemStatusChange: ステータス変更の履歴1件
+type ItemStatusChange struct {
+	FromStatus *ItemStatus `json:"from_status"` // 出品時は null
+	ToStatus   ItemStatus  `json:"to_status"`
+	ActorID    int         `json:"actor_id"` // 変更したユーザー
+	ChangedAt  time.Time   `json:"changed_at"`
+}
@@ -0,0 +1,7 @@
+package model
+
+// SearchHit: 検索インデックスが返す1件分 (商品IDと関連度)
+type SearchHit struct {
+	ItemID int
+	Score  float64
+}
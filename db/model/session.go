@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Session: ログインごとに1行。リフレッシュトークンはハッシュ値だけを持つ
+type Session struct {
+	ID               string     `json:"id"`
+	UserID           int        `json:"user_id"`
+	RefreshTokenHash string     `json:"-"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Active: 失効(ログアウト)しておらず、期限内かどうか
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
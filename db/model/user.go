@@ -1,7 +1,39 @@
 package model
 
+import "time"
+
 type User struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`  // ログイン名 (ソーシャルログイン専用ユーザーは "google:<sub>")
+	Password      string    `json:"-"`     // ハッシュ値でも外には出さない
+	Email         string    `json:"email"` // 未登録なら空 (DB上は NULL)
+	EmailVerified bool      `json:"email_verified"`
+	DisplayName   string    `json:"display_name"`
+	AvatarURL     string    `json:"avatar_url"`
+	Bio           string    `json:"bio"`
+	IsAdmin       bool      `json:"is_admin"` // カテゴリ管理などができる (DB で直接設定する)
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Identity: ユーザーに紐付いた外部ID (Google ログインなど)
+// 1ユーザーがパスワードと複数のプロバイダーを併用できる
+type Identity struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Provider  string    `json:"provider"` // "google"
+	Subject   string    `json:"-"`        // プロバイダー側のユーザーID (sub)
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PublicUser: 他のユーザーに見せてよいプロフィール (認証情報やメアドは含めない)
+type PublicUser struct {
+	ID           int       `json:"id"`
+	DisplayName  string    `json:"display_name"`
+	AvatarURL    string    `json:"avatar_url"`
+	Bio          string    `json:"bio"`
+	JoinedAt     time.Time `json:"joined_at"`
+	ListingCount int       `json:"listing_count"` // 出品数
+	Rating       *float64  `json:"rating"`        // 評価の平均。評価機能ができるまでは常に null
 }
@@ -0,0 +1,61 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Highlight: text の中で terms (正規化済み) に一致する部分を <em></em> で囲む
+// 一致判定は正規化後の文字列で行うので、「すまほ」で「ｽﾏﾎ」もハイライトされる
+// そのまま HTML として表示できるように、一致部分以外もエスケープして返す
+func Highlight(text string, terms []string) string {
+	runes, spans := normalizeWithSpans(text)
+	normalized := string(runes)
+
+	// 元の文字列で一致している範囲 (バイト単位) に印を付ける
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		termLen := len([]rune(term))
+		for from := 0; ; {
+			idx := strings.Index(normalized[from:], term)
+			if idx < 0 {
+				break
+			}
+			// バイト位置を rune の位置に直す
+			startRune := len([]rune(normalized[:from+idx]))
+			for i := startRune; i < startRune+termLen; i++ {
+				for b := spans[i].start; b < spans[i].end; b++ {
+					marked[b] = true
+				}
+			}
+			from += idx + len(term)
+		}
+	}
+
+	var sb strings.Builder
+	open := false
+	last := 0
+	flush := func(to int) {
+		sb.WriteString(html.EscapeString(text[last:to]))
+		last = to
+	}
+	for i := range text {
+		if marked[i] != open {
+			flush(i)
+			if marked[i] {
+				sb.WriteString("<em>")
+			} else {
+				sb.WriteString("</em>")
+			}
+			open = marked[i]
+		}
+	}
+	flush(len(text))
+	if open {
+		sb.WriteString("</em>")
+	}
+	return sb.String()
+}
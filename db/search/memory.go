@@ -0,0 +1,128 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"db/model"
+)
+
+// 何文字ずつに区切るか (MySQL の ngram_token_size のデフォルトと同じ)
+const ngramSize = 2
+
+// 商品名での一致は説明文より重く数える
+const nameWeight = 2.0
+
+type memoryDoc struct {
+	name string // 正規化済み
+	body string // 正規化済み (商品名 + 説明文)
+}
+
+// MemoryIndex: メモリ上の n-gram 転置インデックス
+// テストやDBなしのローカル開発で MySQL の FULLTEXT 検索の代わりに使う (usecase.ItemSearchIndex を満たす)
+// メモリ上なので ctx は使わない
+type MemoryIndex struct {
+	mu    sync.RWMutex
+	docs  map[int]memoryDoc
+	grams map[string]map[int]bool // n-gram → その断片を含む商品ID
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:  make(map[int]memoryDoc),
+		grams: make(map[string]map[int]bool),
+	}
+}
+
+// Index: 商品を登録する (登録済みなら置き換える)
+func (m *MemoryIndex) Index(ctx context.Context, item *model.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(item.ID)
+	doc := memoryDoc{
+		name: Normalize(item.Name),
+		body: Normalize(item.Name + "\n" + item.Description),
+	}
+	m.docs[item.ID] = doc
+	for _, g := range NGrams(doc.body, ngramSize) {
+		if m.grams[g] == nil {
+			m.grams[g] = make(map[int]bool)
+		}
+		m.grams[g][item.ID] = true
+	}
+	return nil
+}
+
+// Remove: 商品をインデックスから外す
+func (m *MemoryIndex) Remove(ctx context.Context, itemID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(itemID)
+	return nil
+}
+
+func (m *MemoryIndex) remove(itemID int) {
+	doc, ok := m.docs[itemID]
+	if !ok {
+		return
+	}
+	for _, g := range NGrams(doc.body, ngramSize) {
+		delete(m.grams[g], itemID)
+	}
+	delete(m.docs, itemID)
+}
+
+// Search: すべての語を含む商品を関連度順に返す (関連度が同じなら新しいID順)
+func (m *MemoryIndex) Search(ctx context.Context, terms []string, limit, offset int) ([]model.SearchHit, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var hits []model.SearchHit
+	for id, doc := range m.docs {
+		score := 0.0
+		matched := true
+		for _, term := range terms {
+			if !strings.Contains(doc.body, term) {
+				matched = false
+				break
+			}
+			score += m.termScore(term, doc.body) + nameWeight*m.termScore(term, doc.name)
+		}
+		if matched && len(terms) > 0 {
+			hits = append(hits, model.SearchHit{ItemID: id, Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ItemID > hits[j].ItemID
+	})
+
+	total := len(hits)
+	if offset >= total {
+		return []model.SearchHit{}, total, nil
+	}
+	end := min(offset+limit, total)
+	return hits[offset:end], total, nil
+}
+
+// termScore: TF-IDF (語の n-gram ごとに、出現回数 × 珍しさ)
+func (m *MemoryIndex) termScore(term, text string) float64 {
+	n := float64(len(m.docs))
+	score := 0.0
+	for _, g := range NGrams(term, ngramSize) {
+		tf := float64(strings.Count(text, g))
+		if tf == 0 {
+			continue
+		}
+		df := float64(len(m.grams[g]))
+		score += tf * math.Log(1+n/math.Max(df, 1))
+	}
+	return score
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize: 検索用に文字列を正規化する
+//   - NFKC: 全角英数字→半角、半角カナ→全角 (濁点の結合も含む)
+//   - ひらがな→カタカナ
+//   - 英字は小文字
+//
+// 「ｽﾏﾎ」「すまほ」「スマホ」がすべて「スマホ」になる
+func Normalize(s string) string {
+	runes, _ := normalizeWithSpans(s)
+	return string(runes)
+}
+
+// span: 正規化後の1文字が、元の文字列のどのバイト範囲から来たか
+type span struct {
+	start, end int
+}
+
+// normalizeWithSpans: 正規化した文字列と、各文字の元の位置を返す (ハイライト用)
+func normalizeWithSpans(s string) ([]rune, []span) {
+	var runes []rune
+	var spans []span
+
+	var it norm.Iter
+	it.InitString(norm.NFKC, s)
+	start := 0
+	for !it.Done() {
+		seg := it.Next()
+		end := it.Pos()
+		for _, r := range string(seg) {
+			runes = append(runes, foldRune(r))
+			spans = append(spans, span{start, end})
+		}
+		start = end
+	}
+	return runes, spans
+}
+
+func foldRune(r rune) rune {
+	// ひらがな (ぁ〜ゖ) はカタカナ (ァ〜ヶ) と 0x60 ずれている
+	if r >= 'ぁ' && r <= 'ゖ' {
+		return r + 0x60
+	}
+	return unicode.ToLower(r)
+}
+
+// Terms: 検索クエリを正規化し、空白区切りの語に分ける
+func Terms(query string) []string {
+	return strings.Fields(Normalize(query))
+}
+
+// NGrams: 正規化済みの語を n 文字ずつの断片に分ける (n-gram)
+// 語が n 文字より短ければ、語そのものを1つだけ返す
+func NGrams(term string, n int) []string {
+	runes := []rune(term)
+	if len(runes) <= n {
+		return []string{term}
+	}
+	grams := make([]string, 0, len(runes)-n+1)
+	for i := 0; i+n <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+n]))
+	}
+	return grams
+}
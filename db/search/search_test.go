@@ -0,0 +1,83 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"db/model"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"スマホ", "スマホ"},
+		{"すまほ", "スマホ"},
+		{"ｽﾏﾎ", "スマホ"},
+		{"ｶﾞﾝﾀﾞﾑ", "ガンダム"},
+		{"ｉＰｈｏｎｅ１５", "iphone15"},
+		{"iPhone 15", "iphone 15"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text  string
+		query string
+		want  string
+	}{
+		{"中古のスマホです", "すまほ", "中古の<em>スマホ</em>です"},
+		{"ｽﾏﾎｹｰｽ", "スマホ", "<em>ｽﾏﾎ</em>ｹｰｽ"},
+		{"ＩＰＨＯＮＥ <新品>", "iphone", "<em>ＩＰＨＯＮＥ</em> &lt;新品&gt;"},
+		{"一致なし", "スマホ", "一致なし"},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.text, Terms(tt.query)); got != tt.want {
+			t.Errorf("Highlight(%q, %q) = %q, want %q", tt.text, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryIndex_Search(t *testing.T) {
+	idx := NewMemoryIndex()
+	items := []model.Item{
+		{ID: 1, Name: "スマホケース", Description: "iPhone用の手帳型ケース"},
+		{ID: 2, Name: "ワンピース", Description: "夏用。スマホが入るポケット付き"},
+		{ID: 3, Name: "ｽﾏﾎ スタンド", Description: "卓上で使えます"},
+		{ID: 4, Name: "小説", Description: "文庫本です"},
+	}
+	for i := range items {
+		if err := idx.Index(context.Background(), &items[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hits, total, err := idx.Search(context.Background(), Terms("すまほ"), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("total = %d, want 3", total)
+	}
+	// 商品名で一致したものが、説明文だけで一致したものより上に来る
+	if hits[len(hits)-1].ItemID != 2 {
+		t.Errorf("hits = %+v, want item 2 last", hits)
+	}
+
+	// 複数語は AND
+	hits, _, _ = idx.Search(context.Background(), Terms("スマホ ケース"), 10, 0)
+	if len(hits) != 1 || hits[0].ItemID != 1 {
+		t.Errorf("AND search hits = %+v, want only item 1", hits)
+	}
+
+	// 削除したものはヒットしない
+	idx.Remove(context.Background(), 1)
+	if _, total, _ := idx.Search(context.Background(), Terms("ケース"), 10, 0); total != 0 {
+		t.Errorf("after remove total = %d, want 0", total)
+	}
+}
@@ -0,0 +1,392 @@
+// Package seed は開発・デモ・負荷試験用のデータを DB に入れる
+// データは fixtures/<セット名>.json に書いてバイナリに埋め込む。投入は `server seed <セット名>` で行う
+// (サーバー本体はフィクスチャを書き込まない)
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+
+	"db/auth"
+	"db/model"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// 生成データは何行ずつまとめて INSERT するか
+const batchSize = 500
+
+var ErrUnknownSet = errors.New("unknown fixture set")
+
+// Fixture: フィクスチャファイル1つ分
+// ID はすべて固定で書く (INSERT IGNORE なので、何回流しても同じ行が増えない)
+type Fixture struct {
+	Include      []string      `json:"include"` // 先に投入するセット
+	Categories   []Category    `json:"categories"`
+	Users        []User        `json:"users"`
+	Items        []Item        `json:"items"`
+	Transactions []Transaction `json:"transactions"`
+	Messages     []Message     `json:"messages"`
+	Generate     *Generate     `json:"generate"` // 負荷試験用に大量のデータを作る
+}
+
+type Category struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ParentID  *int   `json:"parent_id"`
+	SortOrder int    `json:"sort_order"`
+}
+
+type User struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`     // ログイン名
+	Password    string `json:"password"` // 平文で書く (投入時にハッシュ化する)
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	Bio         string `json:"bio"`
+	IsAdmin     bool   `json:"is_admin"`
+}
+
+type Item struct {
+	ID          int              `json:"id"`
+	SellerID    int              `json:"seller_id"`
+	CategoryID  int              `json:"category_id"`
+	Name        string           `json:"name"`
+	Price       int              `json:"price"`
+	Description string           `json:"description"`
+	Status      model.ItemStatus `json:"status"` // 省略したら ON_SALE
+}
+
+type Transaction struct {
+	ID      int `json:"id"`
+	ItemID  int `json:"item_id"`
+	BuyerID int `json:"buyer_id"`
+}
+
+type Message struct {
+	ID         int    `json:"id"`
+	ItemID     int    `json:"item_id"`
+	SenderID   int    `json:"sender_id"`
+	ReceiverID int    `json:"receiver_id"`
+	Content    string `json:"content"`
+}
+
+// Generate: ユーザー・商品・メッセージを機械的に作る
+// ID は FirstID から連番。乱数のシードも固定なので、何回流しても同じデータになる
+type Generate struct {
+	FirstID     int      `json:"first_id"`
+	Seed        int64    `json:"seed"`
+	Users       int      `json:"users"`
+	Items       int      `json:"items"`
+	Messages    int      `json:"messages"`
+	SoldRatio   float64  `json:"sold_ratio"` // 取引完了にする商品の割合
+	Password    string   `json:"password"`   // 全員共通
+	CategoryIDs []int    `json:"category_ids"`
+	Adjectives  []string `json:"adjectives"`
+	Nouns       []string `json:"nouns"`
+}
+
+// Result: テーブルごとに実際に追加した行数 (すでにあった行は数えない)
+type Result map[string]int64
+
+func (r Result) String() string {
+	tables := make([]string, 0, len(r))
+	for t := range r {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	parts := make([]string, len(tables))
+	for i, t := range tables {
+		parts[i] = fmt.Sprintf("%s=%d", t, r[t])
+	}
+	return strings.Join(parts, " ")
+}
+
+// Sets: 使えるセット名
+func Sets() []string {
+	entries, _ := fs.ReadDir(fixtures, "fixtures")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+	return names
+}
+
+// Load: セットを読み込む。include しているセットも含めて、先に入れるものから順に返す
+func Load(name string) ([]*Fixture, error) {
+	return load(name, map[string]bool{})
+}
+
+func load(name string, seen map[string]bool) ([]*Fixture, error) {
+	if seen[name] {
+		return nil, nil
+	}
+	seen[name] = true
+
+	data, err := fixtures.ReadFile("fixtures/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSet, name)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", name, err)
+	}
+
+	var sets []*Fixture
+	for _, inc := range f.Include {
+		included, err := load(inc, seen)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, included...)
+	}
+	return append(sets, &f), nil
+}
+
+type Seeder struct {
+	DB     *sql.DB
+	Hasher *auth.PasswordHasher
+}
+
+func NewSeeder(db *sql.DB, hasher *auth.PasswordHasher) *Seeder {
+	return &Seeder{DB: db, Hasher: hasher}
+}
+
+// Run: セットを1つのトランザクションで投入する
+func (s *Seeder) Run(ctx context.Context, name string) (Result, error) {
+	sets, err := Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	w := &writer{ctx: ctx, tx: tx, hasher: s.Hasher, hashes: map[string]string{}, result: Result{}}
+	for _, f := range sets {
+		if err := w.fixture(f); err != nil {
+			return nil, err
+		}
+	}
+	// messages は直接入れているので、商品の問い合わせ数 (人気順のキー) を数え直す
+	if _, err := tx.ExecContext(ctx, "UPDATE items i SET message_count = (SELECT COUNT(*) FROM messages m WHERE m.item_id = i.id)"); err != nil {
+		return nil, fmt.Errorf("seed message_count: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return w.result, nil
+}
+
+type writer struct {
+	ctx    context.Context
+	tx     *sql.Tx
+	hasher *auth.PasswordHasher
+	hashes map[string]string // 同じパスワードは1回だけハッシュ化する (bcrypt は遅いので)
+	result Result
+}
+
+func (w *writer) fixture(f *Fixture) error {
+	// 親カテゴリを先に入れる必要があるので、ファイルに書いた順で入れる
+	var rows [][]any
+	for _, c := range f.Categories {
+		rows = append(rows, []any{c.ID, c.Name, c.ParentID, c.SortOrder})
+	}
+	if err := w.insert("categories", []string{"id", "name", "parent_id", "sort_order"}, rows); err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, u := range f.Users {
+		row, err := w.userRow(u)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	if err := w.insert("users", userColumns, rows); err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, it := range f.Items {
+		rows = append(rows, itemRow(it))
+	}
+	if err := w.insert("items", itemColumns, rows); err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, t := range f.Transactions {
+		rows = append(rows, []any{t.ID, t.ItemID, t.BuyerID})
+	}
+	if err := w.insert("transactions", transactionColumns, rows); err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, m := range f.Messages {
+		rows = append(rows, []any{m.ID, m.ItemID, m.SenderID, m.ReceiverID, m.Content})
+	}
+	if err := w.insert("messages", messageColumns, rows); err != nil {
+		return err
+	}
+
+	if f.Generate != nil {
+		return w.generate(f.Generate)
+	}
+	return nil
+}
+
+var (
+	userColumns        = []string{"id", "name", "password", "display_name", "email", "email_verified", "bio", "is_admin"}
+	itemColumns        = []string{"id", "seller_id", "category_id", "name", "price", "description", "status"}
+	transactionColumns = []string{"id", "item_id", "buyer_id"}
+	messageColumns     = []string{"id", "item_id", "sender_id", "receiver_id", "content"}
+)
+
+func (w *writer) userRow(u User) ([]any, error) {
+	hash, err := w.hash(u.Password)
+	if err != nil {
+		return nil, err
+	}
+	displayName := u.DisplayName
+	if displayName == "" {
+		displayName = u.Name
+	}
+	var email *string // 未登録は NULL (UNIQUE なので空文字は入れない)
+	if u.Email != "" {
+		email = &u.Email
+	}
+	return []any{u.ID, u.Name, hash, displayName, email, email != nil, u.Bio, u.IsAdmin}, nil
+}
+
+func itemRow(it Item) []any {
+	status := it.Status
+	if status == "" {
+		status = model.ItemStatusOnSale
+	}
+	return []any{it.ID, it.SellerID, it.CategoryID, it.Name, it.Price, it.Description, string(status)}
+}
+
+func (w *writer) hash(password string) (string, error) {
+	if password == "" {
+		return "", nil // パスワードなし (ソーシャルログイン専用と同じ扱い)
+	}
+	if h, ok := w.hashes[password]; ok {
+		return h, nil
+	}
+	h, err := w.hasher.Hash(password)
+	if err != nil {
+		return "", err
+	}
+	w.hashes[password] = h
+	return h, nil
+}
+
+// generate: 負荷試験用のデータを作る
+func (w *writer) generate(g *Generate) error {
+	if g.Users < 2 || len(g.CategoryIDs) == 0 || len(g.Adjectives) == 0 || len(g.Nouns) == 0 {
+		return fmt.Errorf("generate: users (2 or more), category_ids, adjectives and nouns are required")
+	}
+	rnd := rand.New(rand.NewSource(g.Seed))
+	userID := func(i int) int { return g.FirstID + i }
+
+	var rows [][]any
+	for i := 0; i < g.Users; i++ {
+		row, err := w.userRow(User{ID: userID(i), Name: fmt.Sprintf("loadtest%06d", i), Password: g.Password})
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	if err := w.insert("users", userColumns, rows); err != nil {
+		return err
+	}
+
+	type sold struct{ itemID, sellerID, buyerID int }
+	var solds []sold
+	sellers := make([]int, g.Items)
+	rows = nil
+	for i := 0; i < g.Items; i++ {
+		it := Item{
+			ID:         g.FirstID + i,
+			SellerID:   userID(rnd.Intn(g.Users)),
+			CategoryID: g.CategoryIDs[rnd.Intn(len(g.CategoryIDs))],
+			Name:       g.Adjectives[rnd.Intn(len(g.Adjectives))] + " " + g.Nouns[rnd.Intn(len(g.Nouns))],
+			Price:      (rnd.Intn(1000) + 3) * 100,
+		}
+		it.Description = fmt.Sprintf("負荷試験用の商品です (%d)。%s", it.ID, it.Name)
+		if rnd.Float64() < g.SoldRatio {
+			it.Status = model.ItemStatusCompleted
+			solds = append(solds, sold{it.ID, it.SellerID, otherUser(rnd, g, it.SellerID)})
+		}
+		sellers[i] = it.SellerID
+		rows = append(rows, itemRow(it))
+	}
+	if err := w.insert("items", itemColumns, rows); err != nil {
+		return err
+	}
+
+	rows = nil
+	for i, s := range solds {
+		rows = append(rows, []any{g.FirstID + i, s.itemID, s.buyerID})
+	}
+	if err := w.insert("transactions", transactionColumns, rows); err != nil {
+		return err
+	}
+
+	rows = nil
+	if g.Items > 0 {
+		for i := 0; i < g.Messages; i++ {
+			n := rnd.Intn(g.Items)
+			sender := otherUser(rnd, g, sellers[n])
+			rows = append(rows, []any{g.FirstID + i, g.FirstID + n, sender, sellers[n], "購入を検討しています。まだありますか？"})
+		}
+	}
+	return w.insert("messages", messageColumns, rows)
+}
+
+// otherUser: 生成したユーザーのうち not 以外の誰か
+func otherUser(rnd *rand.Rand, g *Generate, not int) int {
+	id := g.FirstID + rnd.Intn(g.Users-1)
+	if id >= not {
+		id++
+	}
+	return id
+}
+
+// insert: INSERT IGNORE でまとめて入れる (主キーが同じ行はそのまま)
+func (w *writer) insert(table string, columns []string, rows [][]any) error {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+		values := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*len(columns))
+		for i, r := range batch {
+			values[i] = row
+			args = append(args, r...)
+		}
+		query := fmt.Sprintf("INSERT IGNORE INTO %s (%s) VALUES %s", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+		res, err := w.tx.ExecContext(w.ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("seed %s: %w", table, err)
+		}
+		n, _ := res.RowsAffected()
+		w.result[table] += n
+	}
+	return nil
+}
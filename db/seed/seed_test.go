@@ -0,0 +1,93 @@
+package seed
+
+import (
+	"errors"
+	"testing"
+)
+
+// フィクスチャ同士の参照がつながっているか (DB の外部キーやカテゴリの葉チェックに引っかからないか)
+func TestFixturesConsistent(t *testing.T) {
+	for _, name := range Sets() {
+		t.Run(name, func(t *testing.T) {
+			sets, err := Load(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			categories := map[int]*int{}
+			hasChild := map[int]bool{}
+			users := map[int]bool{}
+			sellers := map[int]int{}
+			for _, f := range sets {
+				for _, c := range f.Categories {
+					if c.ParentID != nil {
+						if _, ok := categories[*c.ParentID]; !ok {
+							t.Errorf("category %d: 親 %d が先に定義されていない", c.ID, *c.ParentID)
+						}
+						hasChild[*c.ParentID] = true
+					}
+					categories[c.ID] = c.ParentID
+				}
+				for _, u := range f.Users {
+					if users[u.ID] {
+						t.Errorf("user %d が重複している", u.ID)
+					}
+					users[u.ID] = true
+				}
+				for _, it := range f.Items {
+					if _, ok := sellers[it.ID]; ok {
+						t.Errorf("item %d が重複している", it.ID)
+					}
+					sellers[it.ID] = it.SellerID
+					if !users[it.SellerID] {
+						t.Errorf("item %d: 出品者 %d がいない", it.ID, it.SellerID)
+					}
+					if it.Status != "" && !it.Status.Valid() {
+						t.Errorf("item %d: 不正なステータス %s", it.ID, it.Status)
+					}
+				}
+				for _, tr := range f.Transactions {
+					seller, ok := sellers[tr.ItemID]
+					if !ok || !users[tr.BuyerID] || seller == tr.BuyerID {
+						t.Errorf("transaction %d: 商品か購入者がおかしい", tr.ID)
+					}
+				}
+				for _, m := range f.Messages {
+					if _, ok := sellers[m.ItemID]; !ok || !users[m.SenderID] || !users[m.ReceiverID] {
+						t.Errorf("message %d: 商品かユーザーがいない", m.ID)
+					}
+				}
+				if g := f.Generate; g != nil {
+					for _, id := range g.CategoryIDs {
+						if _, ok := categories[id]; !ok || hasChild[id] {
+							t.Errorf("generate: カテゴリ %d が葉ではない", id)
+						}
+					}
+				}
+			}
+			// 商品は葉カテゴリにしか出品できない
+			for _, f := range sets {
+				for _, it := range f.Items {
+					if _, ok := categories[it.CategoryID]; !ok || hasChild[it.CategoryID] {
+						t.Errorf("item %d: カテゴリ %d が葉ではない", it.ID, it.CategoryID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLoadIncludesInOrder(t *testing.T) {
+	sets, err := Load("load-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 3 || len(sets[0].Users) == 0 || sets[0].Users[0].ID != 1 || sets[2].Generate == nil {
+		t.Errorf("minimal → demo → load-test の順になっていない")
+	}
+}
+
+func TestLoadUnknownSet(t *testing.T) {
+	if _, err := Load("nope"); !errors.Is(err, ErrUnknownSet) {
+		t.Errorf("got %v", err)
+	}
+}
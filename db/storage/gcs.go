@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
+)
+
+// GCSStorage: Cloud Storage のバケットに保存する (本番用)
+// 認証は Vertex AI と同じくアプリケーションのデフォルト認証情報を使う
+type GCSStorage struct {
+	Bucket  string
+	service *gcs.Service
+}
+
+func NewGCSStorage(ctx context.Context, bucket string, opts ...option.ClientOption) (*GCSStorage, error) {
+	service, err := gcs.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GCSStorage{Bucket: bucket, service: service}, nil
+}
+
+func (s *GCSStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	object := &gcs.Object{Name: key, ContentType: contentType}
+	_, err := s.service.Objects.Insert(s.Bucket, object).
+		Media(bytes.NewReader(data), googleapi.ContentType(contentType)).
+		Context(ctx).
+		Do()
+	return err
+}
+
+func (s *GCSStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.service.Objects.Get(s.Bucket, key).Context(ctx).Download()
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *GCSStorage) Delete(ctx context.Context, key string) error {
+	err := s.service.Objects.Delete(s.Bucket, key).Context(ctx).Do()
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
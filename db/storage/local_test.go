@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir())
+
+	if err := s.Put(ctx, "images/ab/abc.jpg", "image/jpeg", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Open(ctx, "images/ab/abc.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != "data" {
+		t.Errorf("got %q", got)
+	}
+
+	if err := s.Delete(ctx, "images/ab/abc.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(ctx, "images/ab/abc.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStorage_InvalidKey(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	for _, key := range []string{"", "../secret", "/etc/passwd", "a/../../b"} {
+		if err := s.Put(context.Background(), key, "text/plain", nil); err == nil {
+			t.Errorf("Put(%q) should fail", key)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Package storage はアップロードされたファイルの保存先を抽象化する
+// 開発・テストではローカルのディレクトリ、本番では Cloud Storage を使う
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+type Storage interface {
+	// Put: key に保存する (同じ key があれば上書き)
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Open: key の中身を読む。無ければ ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
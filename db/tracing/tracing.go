@@ -0,0 +1,124 @@
+// Package tracing は OpenTelemetry のトレースの設定
+//
+// Setup でグローバルな TracerProvider を入れ替える。入れ替えなければ (exporter が none) otel の no-op のまま
+// なので、各所の Start は何もしない (テストでもそのまま動く)
+//
+// スパンを作るのは:
+//   - controller.Trace: HTTP リクエスト1つ
+//   - dao: クエリ1つ (SQL はプレースホルダのまま、値は載せない) と購入のトランザクション
+//   - Gemini / Vertex AI Search の呼び出し
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"db/buildinfo"
+	"db/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "db"
+
+// Tracer: このアプリのスパンを作る Tracer (Setup の前に呼んでもよい)
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup: cfg に従って exporter を作り、グローバルな TracerProvider にする
+// 返す shutdown は終了時に呼ぶ (溜まっているスパンを送り切る)
+func Setup(ctx context.Context, cfg config.Tracing, env string) (shutdown func(context.Context) error, err error) {
+	// traceparent ヘッダーは exporter に関係なく受け渡す (前段のロードバランサーなどのトレースにつながるように)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch strings.ToLower(cfg.Exporter) {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx, otlpEndpoint(cfg.Endpoint))
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(buildinfo.Get().Commit),
+		semconv.DeploymentEnvironmentName(env),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// otlpEndpoint: "localhost:4318" ならホストとして、"http(s)://..." なら URL として扱う
+// (http:// のときは TLS なし)
+func otlpEndpoint(endpoint string) otlptracehttp.Option {
+	if strings.Contains(endpoint, "://") {
+		return otlptracehttp.WithEndpointURL(endpoint)
+	}
+	return otlptracehttp.WithEndpoint(endpoint)
+}
+
+var (
+	sqlStrings = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	sqlNumbers = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlSpaces  = regexp.MustCompile(`\s+`)
+)
+
+// maxStatementLen: これより長い SQL は切り詰める (IN (?, ?, ...) が長くなることがある)
+const maxStatementLen = 2000
+
+// SanitizeSQL: スパンに載せる SQL
+// 値は基本プレースホルダで渡しているが、SQL に直接書いた文字列・数値も ? にしておく
+func SanitizeSQL(query string) string {
+	s := sqlStrings.ReplaceAllString(query, "?")
+	s = sqlNumbers.ReplaceAllString(s, "?")
+	s = strings.TrimSpace(sqlSpaces.ReplaceAllString(s, " "))
+	if len(s) > maxStatementLen {
+		s = s[:maxStatementLen] + "..."
+	}
+	return s
+}
+
+// SQLOperation: "SELECT" / "INSERT" など (スパン名に使う)
+func SQLOperation(query string) string {
+	f := strings.Fields(query)
+	if len(f) == 0 {
+		return ""
+	}
+	return strings.ToUpper(f[0])
+}
+
+// RecordError: err があればスパンをエラーにする
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"db/config"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT id\n\t\tFROM items\n\t\tWHERE id = ?", "SELECT id FROM items WHERE id = ?"},
+		{"UPDATE users SET search_name = '' WHERE email = 'a@example.com'", "UPDATE users SET search_name = ? WHERE email = ?"},
+		{"SELECT * FROM users WHERE CHAR_LENGTH(password) = 60 AND name = 'it''s'", "SELECT * FROM users WHERE CHAR_LENGTH(password) = ? AND name = ?"},
+		{"SELECT * FROM item_images2 LIMIT 10", "SELECT * FROM item_images2 LIMIT ?"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeSQL(tt.query); got != tt.want {
+			t.Errorf("SanitizeSQL(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSQLOperation(t *testing.T) {
+	if got := SQLOperation("\n\t\tselect id FROM items"); got != "SELECT" {
+		t.Errorf("got %q", got)
+	}
+	if got := SQLOperation(""); got != "" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{"none", "stdout"} {
+		shutdown, err := Setup(context.Background(), config.Tracing{Exporter: exporter, SampleRatio: 1, ServiceName: "test"}, config.EnvLocal)
+		if err != nil {
+			t.Fatalf("%s: %v", exporter, err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("%s: shutdown: %v", exporter, err)
+		}
+	}
+	if _, err := Setup(context.Background(), config.Tracing{Exporter: "zipkin"}, config.EnvLocal); err == nil {
+		t.Error("unknown exporter should fail")
+	}
+}
@@ -0,0 +1,209 @@
+package usecase
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"db/apperr"
+	"db/model"
+)
+
+var (
+	ErrCategoryNotFound = apperr.New(apperr.NotFound, "category_not_found", "category not found")
+	ErrInvalidCategory  = apperr.New(apperr.Validation, "invalid_category", "invalid category")
+	ErrCategoryInUse    = apperr.New(apperr.Conflict, "category_in_use", "category has items or subcategories")
+	ErrAdminOnly        = apperr.New(apperr.Forbidden, "admin_only", "only admins can do this")
+)
+
+const maxCategoryNameLength = 50
+
+type CategoryUsecase struct {
+	Repo  CategoryRepository
+	Users UserRepository
+}
+
+func NewCategoryUsecase(repo CategoryRepository, users UserRepository) *CategoryUsecase {
+	return &CategoryUsecase{Repo: repo, Users: users}
+}
+
+// GetTree: カテゴリの木 (各階層は表示順)
+func (u *CategoryUsecase) GetTree(ctx context.Context) ([]model.CategoryNode, error) {
+	tree, err := loadCategoryTree(ctx, u.Repo)
+	if err != nil {
+		return nil, err
+	}
+	return tree.nodes(0), nil
+}
+
+// カテゴリの作成・編集のリクエスト (null の項目は変更しない)
+// parent_id に 0 を指定すると一番上の階層に移す
+type CategoryReq struct {
+	Name      *string `json:"name"`
+	ParentID  *int    `json:"parent_id"`
+	SortOrder *int    `json:"sort_order"`
+}
+
+func (u *CategoryUsecase) CreateCategory(ctx context.Context, actorID int, req CategoryReq) (int, error) {
+	if err := u.requireAdmin(ctx, actorID); err != nil {
+		return 0, err
+	}
+	tree, err := loadCategoryTree(ctx, u.Repo)
+	if err != nil {
+		return 0, err
+	}
+
+	c := &model.Category{}
+	if err := u.apply(ctx, tree, c, req); err != nil {
+		return 0, err
+	}
+	if c.Name == "" {
+		return 0, ErrInvalidCategory.With("field", "name").With("reason", "required")
+	}
+	return u.Repo.Insert(ctx, c)
+}
+
+func (u *CategoryUsecase) UpdateCategory(ctx context.Context, actorID, id int, req CategoryReq) error {
+	if err := u.requireAdmin(ctx, actorID); err != nil {
+		return err
+	}
+	tree, err := loadCategoryTree(ctx, u.Repo)
+	if err != nil {
+		return err
+	}
+	c, ok := tree.byID[id]
+	if !ok {
+		return ErrCategoryNotFound
+	}
+	if err := u.apply(ctx, tree, &c, req); err != nil {
+		return err
+	}
+	return u.Repo.Update(ctx, &c)
+}
+
+// DeleteCategory: 子カテゴリや商品があるカテゴリは消せない
+func (u *CategoryUsecase) DeleteCategory(ctx context.Context, actorID, id int) error {
+	if err := u.requireAdmin(ctx, actorID); err != nil {
+		return err
+	}
+	tree, err := loadCategoryTree(ctx, u.Repo)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.byID[id]; !ok {
+		return ErrCategoryNotFound
+	}
+	if !tree.isLeaf(id) {
+		return ErrCategoryInUse
+	}
+	if err := u.checkNoItems(ctx, id); err != nil {
+		return err
+	}
+	return u.Repo.Delete(ctx, id)
+}
+
+// apply: リクエストの内容を c に反映する (親を変えるときは木が壊れないか確認する)
+func (u *CategoryUsecase) apply(ctx context.Context, tree *categoryTree, c *model.Category, req CategoryReq) error {
+	if req.Name != nil {
+		if *req.Name == "" || utf8.RuneCountInString(*req.Name) > maxCategoryNameLength {
+			return ErrInvalidCategory.With("field", "name").With("max_length", maxCategoryNameLength)
+		}
+		c.Name = *req.Name
+	}
+	if req.SortOrder != nil {
+		c.SortOrder = *req.SortOrder
+	}
+	if req.ParentID == nil {
+		return nil
+	}
+
+	parentID := *req.ParentID
+	if parentID == 0 {
+		c.ParentID = nil
+		return nil
+	}
+	if _, ok := tree.byID[parentID]; !ok {
+		return ErrInvalidCategory.With("field", "parent_id").With("reason", "not_found")
+	}
+	// 自分や自分の子孫を親にすると循環してしまう
+	if c.ID != 0 {
+		for _, id := range tree.descendants(c.ID) {
+			if id == parentID {
+				return ErrInvalidCategory.With("field", "parent_id").With("reason", "cycle")
+			}
+		}
+	}
+	// 商品があるカテゴリに子を作ると、商品が葉でないカテゴリに残ってしまう
+	if tree.isLeaf(parentID) {
+		if err := u.checkNoItems(ctx, parentID); err != nil {
+			return err
+		}
+	}
+	c.ParentID = &parentID
+	return nil
+}
+
+func (u *CategoryUsecase) checkNoItems(ctx context.Context, id int) error {
+	n, err := u.Repo.CountItems(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrCategoryInUse.With("category_id", id).With("items", n)
+	}
+	return nil
+}
+
+func (u *CategoryUsecase) requireAdmin(ctx context.Context, userID int) error {
+	user, err := u.Users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsAdmin {
+		return ErrAdminOnly
+	}
+	return nil
+}
+
+// categoryTree: カテゴリ一覧から作った親子関係
+type categoryTree struct {
+	byID     map[int]model.Category
+	children map[int][]int // 親ID (一番上は 0) → 子ID (表示順)
+}
+
+func loadCategoryTree(ctx context.Context, repo CategoryRepository) (*categoryTree, error) {
+	categories, err := repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t := &categoryTree{byID: make(map[int]model.Category), children: make(map[int][]int)}
+	for _, c := range categories {
+		t.byID[c.ID] = c
+		parent := 0
+		if c.ParentID != nil {
+			parent = *c.ParentID
+		}
+		t.children[parent] = append(t.children[parent], c.ID)
+	}
+	return t, nil
+}
+
+func (t *categoryTree) isLeaf(id int) bool {
+	return len(t.children[id]) == 0
+}
+
+// descendants: id 自身とその子孫すべて
+func (t *categoryTree) descendants(id int) []int {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+	return ids
+}
+
+func (t *categoryTree) nodes(parent int) []model.CategoryNode {
+	nodes := []model.CategoryNode{}
+	for _, id := range t.children[parent] {
+		nodes = append(nodes, model.CategoryNode{Category: t.byID[id], Children: t.nodes(id)})
+	}
+	return nodes
+}
@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"db/model"
+)
+
+type MockCategoryRepo struct {
+	categories []model.Category
+	items      map[int]int // カテゴリID → 商品数
+}
+
+// List: 本物の DAO と同じく、ctx が切れていたらエラー
+func (m *MockCategoryRepo) List(ctx context.Context) ([]model.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.categories, nil
+}
+func (m *MockCategoryRepo) Insert(ctx context.Context, c *model.Category) (int, error) {
+	c.ID = len(m.categories) + 1
+	m.categories = append(m.categories, *c)
+	return c.ID, nil
+}
+func (m *MockCategoryRepo) Update(ctx context.Context, c *model.Category) error { return nil }
+func (m *MockCategoryRepo) Delete(ctx context.Context, id int) error            { return nil }
+func (m *MockCategoryRepo) CountItems(ctx context.Context, id int) (int, error) {
+	return m.items[id], nil
+}
+
+func parent(id int) *int { return &id }
+
+// 1 本・雑誌 ─ 4 漫画 ─ 5 少年漫画
+// 2 家電・スマホ
+// 3 ファッション (商品あり)
+func newCategoryUsecase() *CategoryUsecase {
+	repo := &MockCategoryRepo{
+		categories: []model.Category{
+			{ID: 1, Name: "本・雑誌"},
+			{ID: 2, Name: "家電・スマホ"},
+			{ID: 3, Name: "ファッション"},
+			{ID: 4, Name: "漫画", ParentID: parent(1)},
+			{ID: 5, Name: "少年漫画", ParentID: parent(4)},
+		},
+		items: map[int]int{3: 2},
+	}
+	users := &MockRepo{users: []model.User{{ID: 1, IsAdmin: true}, {ID: 2}}}
+	return NewCategoryUsecase(repo, users)
+}
+
+func TestCategoryTree(t *testing.T) {
+	u := newCategoryUsecase()
+	tree, err := loadCategoryTree(context.Background(), u.Repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.descendants(1); !slices.Equal(got, []int{1, 4, 5}) {
+		t.Errorf("descendants(1) = %v", got)
+	}
+	if tree.isLeaf(4) || !tree.isLeaf(5) {
+		t.Error("isLeaf is wrong")
+	}
+
+	nodes, err := u.GetTree(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 3 || nodes[0].Children[0].Children[0].ID != 5 {
+		t.Errorf("unexpected tree: %+v", nodes)
+	}
+}
+
+func TestCategoryUsecase_Validation(t *testing.T) {
+	name := "新しいカテゴリ"
+	tests := []struct {
+		name    string
+		run     func(u *CategoryUsecase) error
+		wantErr error
+	}{
+		{"管理者以外は作れない", func(u *CategoryUsecase) error {
+			_, err := u.CreateCategory(context.Background(), 2, CategoryReq{Name: &name})
+			return err
+		}, ErrAdminOnly},
+		{"子カテゴリを作る", func(u *CategoryUsecase) error {
+			_, err := u.CreateCategory(context.Background(), 1, CategoryReq{Name: &name, ParentID: parent(5)})
+			return err
+		}, nil},
+		{"商品があるカテゴリの下には作れない", func(u *CategoryUsecase) error {
+			_, err := u.CreateCategory(context.Background(), 1, CategoryReq{Name: &name, ParentID: parent(3)})
+			return err
+		}, ErrCategoryInUse},
+		{"自分の子孫の下には移せない", func(u *CategoryUsecase) error {
+			return u.UpdateCategory(context.Background(), 1, 1, CategoryReq{ParentID: parent(5)})
+		}, ErrInvalidCategory},
+		{"一番上に移す", func(u *CategoryUsecase) error {
+			return u.UpdateCategory(context.Background(), 1, 4, CategoryReq{ParentID: parent(0)})
+		}, nil},
+		{"子があるカテゴリは消せない", func(u *CategoryUsecase) error {
+			return u.DeleteCategory(context.Background(), 1, 4)
+		}, ErrCategoryInUse},
+		{"商品があるカテゴリは消せない", func(u *CategoryUsecase) error {
+			return u.DeleteCategory(context.Background(), 1, 3)
+		}, ErrCategoryInUse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(newCategoryUsecase()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// クライアントが切断したら (ctx がキャンセルされたら) DB まで伝わる
+func TestCategoryCanceled(t *testing.T) {
+	u := newCategoryUsecase()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := u.GetTree(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,193 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"db/apperr"
+	"db/media"
+	"db/model"
+	"db/storage"
+)
+
+var (
+	ErrImageNotFound    = apperr.New(apperr.NotFound, "image_not_found", "image not found")
+	ErrUnknownImageSize = apperr.New(apperr.Validation, "unknown_image_size", "unknown image size (thumb, medium, full)")
+	ErrImageTooLarge    = apperr.New(apperr.TooLarge, "image_too_large", "image is too large").With("max_bytes", MaxImageBytes)
+	ErrUnsupportedImage = media.ErrUnsupportedImage
+)
+
+// アップロードできる画像の大きさ (バイト)
+const MaxImageBytes = 10 << 20
+
+// 1つの商品に付けられる画像の枚数
+const maxItemImages = 10
+
+type ImageUsecase struct {
+	Repo    ImageRepository
+	Storage storage.Storage
+
+	// 画像のデコード・縮小・エンコードを同時にいくつまでやるか (CPU とメモリを食うので、アップロードが重なったら待たせる)
+	encodeSlots chan struct{}
+}
+
+func NewImageUsecase(repo ImageRepository, storage storage.Storage) *ImageUsecase {
+	return &ImageUsecase{Repo: repo, Storage: storage, encodeSlots: make(chan struct{}, runtime.GOMAXPROCS(0))}
+}
+
+// Upload: 画像を検証・加工 (EXIF の削除など) して保存し、登録した画像を返す
+// 一覧・詳細用に、縮小したサイズ違い (JPEG/PNG と WebP) もここで作っておく
+func (u *ImageUsecase) Upload(ctx context.Context, ownerID int, data []byte) (*model.Image, error) {
+	if len(data) > MaxImageBytes {
+		return nil, ErrImageTooLarge
+	}
+	processed, encoded, err := u.process(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// ファイル名は中身のハッシュ (同じ画像を何度上げても1つで済む)
+	sum := sha256.Sum256(processed.Data)
+	hash := hex.EncodeToString(sum[:])
+	prefix := fmt.Sprintf("images/%s/%s", hash[:2], hash)
+	key := prefix + processed.Ext
+	if err := u.Storage.Put(ctx, key, processed.ContentType, processed.Data); err != nil {
+		return nil, err
+	}
+
+	var variants []model.ImageVariant
+	for i, size := range model.ImageSizes {
+		for _, e := range encoded[i] {
+			v := model.ImageVariant{
+				Size:        size,
+				ContentType: e.ContentType,
+				StorageKey:  fmt.Sprintf("%s_%s%s", prefix, size, e.Ext),
+				Width:       e.Width,
+				Height:      e.Height,
+				Bytes:       len(e.Data),
+			}
+			if err := u.Storage.Put(ctx, v.StorageKey, v.ContentType, e.Data); err != nil {
+				return nil, err
+			}
+			variants = append(variants, v)
+		}
+	}
+
+	img := &model.Image{
+		OwnerID:     ownerID,
+		StorageKey:  key,
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Size:        len(processed.Data),
+		Variants:    variants,
+	}
+	id, err := u.Repo.Insert(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+	img.ID = id
+	img.URL = model.ImageURL(id)
+	return img, nil
+}
+
+// process: 画像を加工し、サイズ違い (model.ImageSizes の順) を作る
+// 空きができるまで待つ (待っている間にリクエストがタイムアウト・切断されたら ctx のエラー)
+func (u *ImageUsecase) process(ctx context.Context, data []byte) (*media.Image, [][]media.Encoded, error) {
+	select {
+	case u.encodeSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	defer func() { <-u.encodeSlots }()
+
+	processed, err := media.Sanitize(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded := make([][]media.Encoded, len(model.ImageSizes))
+	for i, size := range model.ImageSizes {
+		encoded[i], err = media.EncodeVariants(media.Resize(processed.Pixels, size.MaxSide()))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return processed, encoded, nil
+}
+
+// Variant: 配信する画像を選ぶ
+// acceptWebP ならブラウザが WebP を表示できるので、小さい方 (JPEG/PNG か WebP) を返す
+// サイズ違いを作る前の古い画像なら、アップロードされた画像そのものを返す
+func (u *ImageUsecase) Variant(ctx context.Context, id int, size model.ImageSize, acceptWebP bool) (*model.ImageVariant, error) {
+	if size.MaxSide() == 0 {
+		return nil, ErrUnknownImageSize
+	}
+	variants, err := u.Repo.FindVariants(ctx, id, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *model.ImageVariant
+	for i, v := range variants {
+		if v.ContentType == "image/webp" && !acceptWebP {
+			continue
+		}
+		if best == nil || v.Bytes < best.Bytes {
+			best = &variants[i]
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	img, err := u.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil {
+		return nil, ErrImageNotFound
+	}
+	return &model.ImageVariant{
+		Size:        size,
+		ContentType: img.ContentType,
+		StorageKey:  img.StorageKey,
+		Width:       img.Width,
+		Height:      img.Height,
+		Bytes:       img.Size,
+	}, nil
+}
+
+// Open: 画像の中身を読む。呼び出し側で Close すること
+func (u *ImageUsecase) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := u.Storage.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrImageNotFound
+	}
+	return body, err
+}
+
+// ReadAll: アップロードされた画像そのものをまとめて読む (Gemini に渡すときなど)
+func (u *ImageUsecase) ReadAll(ctx context.Context, id int) ([]byte, *model.Image, error) {
+	img, err := u.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if img == nil {
+		return nil, nil, ErrImageNotFound
+	}
+	body, err := u.Open(ctx, img.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, img, nil
+}
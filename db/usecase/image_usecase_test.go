@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"db/model"
+	"db/storage"
+)
+
+type MockImageRepo struct {
+	images []model.Image
+}
+
+func (m *MockImageRepo) Insert(ctx context.Context, img *model.Image) (int, error) {
+	img.ID = len(m.images) + 1
+	m.images = append(m.images, *img)
+	return img.ID, nil
+}
+func (m *MockImageRepo) FindByID(ctx context.Context, id int) (*model.Image, error) {
+	for _, img := range m.images {
+		if img.ID == id {
+			return &img, nil
+		}
+	}
+	return nil, nil
+}
+func (m *MockImageRepo) FindByIDs(ctx context.Context, ids []int) ([]model.Image, error) {
+	return nil, nil
+}
+func (m *MockImageRepo) FindByItem(ctx context.Context, itemID int) ([]model.Image, error) {
+	return nil, nil
+}
+func (m *MockImageRepo) FindVariants(ctx context.Context, imageID int, size model.ImageSize) ([]model.ImageVariant, error) {
+	var variants []model.ImageVariant
+	for _, img := range m.images {
+		for _, v := range img.Variants {
+			if img.ID == imageID && v.Size == size {
+				variants = append(variants, v)
+			}
+		}
+	}
+	return variants, nil
+}
+
+func TestImageUsecase_Upload(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for y := range 500 {
+		for x := range 1000 {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &MockImageRepo{}
+	u := NewImageUsecase(repo, storage.NewLocalStorage(t.TempDir()))
+	img, err := u.Upload(context.Background(), 1, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3サイズ × (JPEG と WebP)
+	if len(img.Variants) != 6 {
+		t.Fatalf("variants = %d, want 6", len(img.Variants))
+	}
+
+	thumb, err := u.Variant(context.Background(), img.ID, model.ImageSizeThumb, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.ContentType != "image/jpeg" {
+		t.Errorf("content type = %s, want image/jpeg when webp is not accepted", thumb.ContentType)
+	}
+	if thumb.Width != 240 || thumb.Height != 120 {
+		t.Errorf("thumb = %dx%d, want 240x120", thumb.Width, thumb.Height)
+	}
+
+	// 元画像より大きくはしない
+	full, err := u.Variant(context.Background(), img.ID, model.ImageSizeFull, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Width != 1000 {
+		t.Errorf("full width = %d, want 1000", full.Width)
+	}
+
+	if _, err := u.Variant(context.Background(), img.ID, "huge", true); err != ErrUnknownImageSize {
+		t.Errorf("err = %v, want ErrUnknownImageSize", err)
+	}
+}
+
+// 加工の枠が埋まっていたら待ち、その間に ctx が切れたらあきらめる
+func TestImageUsecase_UploadBusy(t *testing.T) {
+	u := NewImageUsecase(&MockImageRepo{}, storage.NewLocalStorage(t.TempDir()))
+	for range cap(u.encodeSlots) {
+		u.encodeSlots <- struct{}{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := u.Upload(ctx, 1, []byte("not an image")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"db/apperr"
+	"db/model"
+)
+
+var (
+	ErrInvalidTransition   = apperr.New(apperr.Conflict, "invalid_transition", "invalid status transition")
+	ErrTransitionForbidden = apperr.New(apperr.Forbidden, "transition_forbidden", "you are not allowed to make this status change")
+)
+
+// 誰がステータスを変えられるか (ビットで組み合わせる)
+type itemRole int
+
+const (
+	roleSeller itemRole = 1 << iota // 出品者
+	roleBuyer                       // 購入者 (購入時は購入しようとしている人)
+)
+
+// itemTransitions: ステータスの遷移表 (変更前 → 変更後 → 変更できる人)
+// ここに無い遷移はすべて拒否する
+var itemTransitions = map[model.ItemStatus]map[model.ItemStatus]itemRole{
+	model.ItemStatusDraft: {
+		model.ItemStatusOnSale:    roleSeller, // 公開
+		model.ItemStatusWithdrawn: roleSeller,
+	},
+	model.ItemStatusOnSale: {
+		model.ItemStatusDraft:     roleSeller, // 非公開に戻す
+		model.ItemStatusReserved:  roleSeller, // 取り置き
+		model.ItemStatusTrading:   roleBuyer,  // 購入 (Purchase からだけ。ChangeStatus では拒否する)
+		model.ItemStatusWithdrawn: roleSeller, // 取り下げ
+	},
+	model.ItemStatusReserved: {
+		model.ItemStatusOnSale:    roleSeller, // 取り置き解除 (取り置き中は誰も買えない)
+		model.ItemStatusWithdrawn: roleSeller,
+	},
+	model.ItemStatusTrading: {
+		model.ItemStatusShipped:   roleSeller,             // 発送
+		model.ItemStatusCancelled: roleSeller | roleBuyer, // 取引キャンセル
+	},
+	model.ItemStatusShipped: {
+		model.ItemStatusCompleted: roleBuyer, // 受け取り
+	},
+	model.ItemStatusCancelled: {
+		model.ItemStatusOnSale:    roleSeller, // 再出品
+		model.ItemStatusWithdrawn: roleSeller,
+	},
+	model.ItemStatusWithdrawn: {
+		model.ItemStatusOnSale: roleSeller, // 再出品
+	},
+	// COMPLETED からはどこへも移れない
+}
+
+// checkTransition: actorID のユーザーが from → to の変更をしてよいか
+// buyerID は取引中の購入者 (購入時は購入しようとしている人、いなければ 0)
+func checkTransition(from, to model.ItemStatus, actorID, sellerID, buyerID int) error {
+	allowed, ok := itemTransitions[from][to]
+	if !ok {
+		return ErrInvalidTransition.With("from", from).With("to", to)
+	}
+
+	var roles itemRole
+	if actorID == sellerID {
+		roles |= roleSeller
+	}
+	if buyerID != 0 && actorID == buyerID {
+		roles |= roleBuyer
+	}
+	if allowed&roles == 0 {
+		return ErrTransitionForbidden.With("from", from).With("to", to)
+	}
+	return nil
+}
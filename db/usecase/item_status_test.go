@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"db/model"
+)
+
+func TestCheckTransition(t *testing.T) {
+	const seller, buyer, other = 1, 2, 3
+	tests := []struct {
+		name    string
+		from    model.ItemStatus
+		to      model.ItemStatus
+		actor   int
+		wantErr error
+	}{
+		{"出品者が取り下げ", model.ItemStatusOnSale, model.ItemStatusWithdrawn, seller, nil},
+		{"他人は取り下げできない", model.ItemStatusOnSale, model.ItemStatusWithdrawn, other, ErrTransitionForbidden},
+		{"購入", model.ItemStatusOnSale, model.ItemStatusTrading, buyer, nil},
+		{"出品者が発送", model.ItemStatusTrading, model.ItemStatusShipped, seller, nil},
+		{"購入者は発送できない", model.ItemStatusTrading, model.ItemStatusShipped, buyer, ErrTransitionForbidden},
+		{"購入者がキャンセル", model.ItemStatusTrading, model.ItemStatusCancelled, buyer, nil},
+		{"出品者がキャンセル", model.ItemStatusTrading, model.ItemStatusCancelled, seller, nil},
+		{"購入者が受け取り", model.ItemStatusShipped, model.ItemStatusCompleted, buyer, nil},
+		{"発送前に完了はできない", model.ItemStatusTrading, model.ItemStatusCompleted, buyer, ErrInvalidTransition},
+		{"完了後は変えられない", model.ItemStatusCompleted, model.ItemStatusOnSale, seller, ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTransition(tt.from, tt.to, tt.actor, seller, buyer); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,38 +1,568 @@
 package usecase
 
-import "db/model"
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+
+	"db/apperr"
+	"db/model"
+	"db/search"
+)
+
+var (
+	ErrItemNotFound    = apperr.New(apperr.NotFound, "item_not_found", "item not found")
+	ErrInvalidCursor   = apperr.New(apperr.Validation, "invalid_cursor", "invalid cursor")
+	ErrInvalidQuery    = apperr.New(apperr.Validation, "invalid_query", "invalid query")
+	ErrInvalidItem     = apperr.New(apperr.Validation, "invalid_item", "invalid item")
+	ErrNotItemSeller   = apperr.New(apperr.Forbidden, "not_item_seller", "only the seller can change this item")
+	ErrItemNotEditable = apperr.New(apperr.Conflict, "item_not_editable", "item can no longer be edited")
+)
+
+const (
+	defaultItemsLimit = 30
+	maxItemsLimit     = 100
+
+	maxItemPrice = 9999999
+)
+
+// 商品詳細に載せる「同じカテゴリの商品」の数
+const relatedItemsLimit = 6
 
 type ItemUsecase struct {
-	Repo ItemRepository
+	Repo       ItemRepository
+	Search     ItemSearchIndex
+	Images     ImageRepository
+	Categories CategoryRepository
 }
 
-func NewItemUsecase(repo ItemRepository) *ItemUsecase {
-	return &ItemUsecase{Repo: repo}
+func NewItemUsecase(repo ItemRepository, search ItemSearchIndex, images ImageRepository, categories CategoryRepository) *ItemUsecase {
+	return &ItemUsecase{Repo: repo, Search: search, Images: images, Categories: categories}
+}
+
+// 一覧取得時のリクエストパラメータ (0 や空文字は「指定なし」)
+type ListItemsReq struct {
+	CategoryID int // 子孫のカテゴリの商品も含む
+	Status     model.ItemStatus
+	MinPrice   int
+	MaxPrice   int
+	SellerID   int
+	ViewerID   int    // ログイン中のユーザー (未ログインなら 0)
+	Sort       string // newest (デフォルト) / price_asc / price_desc / popular
+	Cursor     string // 前のレスポンスの next_cursor
+	Limit      int
+}
+
+// ItemPage: 一覧の1ページ分
+// NextCursor が null なら最後のページ。TotalCount は1ページ目だけ返す (2ページ目以降は null)
+type ItemPage struct {
+	Items      []model.Item `json:"items"`
+	NextCursor *string      `json:"next_cursor"`
+	TotalCount *int         `json:"total_count"`
+}
+
+func (u *ItemUsecase) GetItems(ctx context.Context, req ListItemsReq) (*ItemPage, error) {
+	q, err := u.buildItemQuery(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	items, next, err := u.Repo.ListItems(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []model.Item{}
+	}
+	page := &ItemPage{Items: items}
+
+	if next != nil {
+		cursor, err := encodeItemCursor(next)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = &cursor
+	}
+
+	// 総数は COUNT(*) が必要なので、1ページ目のときだけ数える
+	if q.After == nil {
+		total, err := u.Repo.CountItems(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		page.TotalCount = &total
+	}
+	return page, nil
 }
 
-func (u *ItemUsecase) GetItems() ([]model.Item, error) {
-	return u.Repo.GetItems()
+func (u *ItemUsecase) buildItemQuery(ctx context.Context, req ListItemsReq) (model.ItemQuery, error) {
+	q := model.ItemQuery{
+		Status:   req.Status,
+		MinPrice: req.MinPrice,
+		MaxPrice: req.MaxPrice,
+		SellerID: req.SellerID,
+		Sort:     req.Sort,
+		Limit:    req.Limit,
+		// 下書き・取り下げた商品は、出品者本人が自分の商品を一覧するときだけ見える
+		IncludeHidden: req.SellerID != 0 && req.SellerID == req.ViewerID,
+	}
+
+	if q.Status != "" && !q.Status.Valid() {
+		return q, ErrInvalidQuery.With("field", "status").With("reason", "unknown")
+	}
+	if req.CategoryID != 0 {
+		tree, err := loadCategoryTree(ctx, u.Categories)
+		if err != nil {
+			return q, err
+		}
+		if _, ok := tree.byID[req.CategoryID]; !ok {
+			return q, ErrInvalidQuery.With("field", "category_id").With("reason", "unknown")
+		}
+		q.CategoryIDs = tree.descendants(req.CategoryID)
+	}
+
+	switch q.Sort {
+	case "":
+		q.Sort = model.ItemSortNewest
+	case model.ItemSortNewest, model.ItemSortPriceAsc, model.ItemSortPriceDesc, model.ItemSortPopular:
+	default:
+		return q, ErrInvalidQuery.With("field", "sort").With("reason", "unknown")
+	}
+	if q.MinPrice < 0 || q.MaxPrice < 0 || (q.MaxPrice > 0 && q.MinPrice > q.MaxPrice) {
+		return q, ErrInvalidQuery.With("field", "price").With("reason", "invalid_range")
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultItemsLimit
+	}
+	if q.Limit > maxItemsLimit {
+		q.Limit = maxItemsLimit
+	}
+
+	if req.Cursor != "" {
+		after, err := decodeItemCursor(req.Cursor)
+		if err != nil {
+			return q, err
+		}
+		// 並び順を変えたら、カーソルは使えない
+		if after.Sort != q.Sort {
+			return q, ErrInvalidCursor
+		}
+		q.After = after
+	}
+	return q, nil
+}
+
+// カーソルはフロントからは中身を気にしない文字列として扱ってもらう
+func encodeItemCursor(c *model.ItemCursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeItemCursor(s string) (*model.ItemCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c model.ItemCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// 検索時のリクエストパラメータ
+type SearchItemsReq struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// SearchResult: 検索結果の1件。Highlights は一致部分を <em> で囲んだ HTML (エスケープ済み)
+type SearchResult struct {
+	model.Item
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+type SearchHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// SearchPage: 検索結果の1ページ分。NextOffset が null なら最後のページ
+type SearchPage struct {
+	Items      []SearchResult `json:"items"`
+	NextOffset *int           `json:"next_offset"`
+	TotalCount int            `json:"total_count"`
+}
+
+// SearchItems: 商品名・説明文のキーワード検索 (関連度順)
+func (u *ItemUsecase) SearchItems(ctx context.Context, req SearchItemsReq) (*SearchPage, error) {
+	terms := search.Terms(req.Query)
+	if len(terms) == 0 {
+		return nil, ErrInvalidQuery.With("field", "q").With("reason", "required")
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultItemsLimit
+	}
+	if limit > maxItemsLimit {
+		limit = maxItemsLimit
+	}
+	offset := max(req.Offset, 0)
+
+	hits, total, err := u.Search.Search(ctx, terms, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ItemID
+	}
+	items, err := u.Repo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[int]float64, len(hits))
+	for _, h := range hits {
+		scores[h.ItemID] = h.Score
+	}
+	page := &SearchPage{Items: make([]SearchResult, 0, len(items)), TotalCount: total}
+	for _, item := range items {
+		page.Items = append(page.Items, SearchResult{
+			Item:  item,
+			Score: scores[item.ID],
+			Highlights: SearchHighlights{
+				Name:        search.Highlight(item.Name, terms),
+				Description: search.Highlight(item.Description, terms),
+			},
+		})
+	}
+	if offset+limit < total {
+		next := offset + limit
+		page.NextOffset = &next
+	}
+	return page, nil
+}
+
+// ReindexSearch: 公開中の全商品を検索インデックスに登録し直す (正規化ルールを変えたときや初回導入時)
+func (u *ItemUsecase) ReindexSearch(ctx context.Context) (int, error) {
+	q := model.ItemQuery{Sort: model.ItemSortNewest, Limit: maxItemsLimit}
+	count := 0
+	for {
+		items, next, err := u.Repo.ListItems(ctx, q)
+		if err != nil {
+			return count, err
+		}
+		for i := range items {
+			if err := u.Search.Index(ctx, &items[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if next == nil {
+			return count, nil
+		}
+		q.After = next
+	}
+}
+
+// GetItemDetail: 商品詳細 (出品者の情報と同じカテゴリの商品も付ける)
+// viewerID はログイン中のユーザー (未ログインなら 0)。下書き・取り下げた商品は出品者本人にだけ見せる
+func (u *ItemUsecase) GetItemDetail(ctx context.Context, id, viewerID int) (*model.ItemDetail, error) {
+	item, err := u.Repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || (item.Status.Hidden() && item.SellerID != viewerID) {
+		return nil, ErrItemNotFound
+	}
+
+	seller, err := u.Repo.FindSellerSummary(ctx, item.SellerID)
+	if err != nil {
+		return nil, err
+	}
+	if seller == nil {
+		// 退会などで出品者がいない場合も詳細は表示する
+		seller = &model.SellerSummary{ID: item.SellerID}
+	}
+
+	related, err := u.Repo.FindRelated(ctx, item.CategoryID, item.ID, relatedItemsLimit)
+	if err != nil {
+		return nil, err
+	}
+	if related == nil {
+		related = []model.Item{}
+	}
+
+	history, err := u.Repo.GetPriceHistory(ctx, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []model.PriceChange{}
+	}
+
+	images, err := u.Images.FindByItem(ctx, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if images == nil {
+		images = []model.Image{}
+	}
+
+	detail := &model.ItemDetail{
+		Item:         *item,
+		Images:       images,
+		Seller:       *seller,
+		RelatedItems: related,
+		PriceHistory: history,
+	}
+
+	// ステータスの履歴は出品者本人にだけ見せる
+	if item.SellerID == viewerID {
+		detail.StatusHistory, err = u.Repo.GetStatusHistory(ctx, item.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return detail, nil
 }
 
 // 出品時のリクエストパラメータ
 type CreateItemReq struct {
-	SellerID    int    `json:"seller_id"`
+	SellerID    int    `json:"-"` // ログイン中のユーザー (トークンから設定)
 	CategoryID  int    `json:"category_id"`
 	Name        string `json:"name"`
 	Price       int    `json:"price"`
 	Description string `json:"description"`
-	ImageName   string `json:"image_name"`
+	ImageIDs    []int  `json:"image_ids"` // POST /api/images で上げた画像 (表示順)
+	Draft       bool   `json:"draft"`     // true なら下書きとして保存する (公開はステータス変更で)
 }
 
-func (u *ItemUsecase) CreateItem(req CreateItemReq) (int, error) {
-	// ここで金額チェック(マイナスじゃないか等)を入れると良いです
+func (u *ItemUsecase) CreateItem(ctx context.Context, req CreateItemReq) (int, error) {
+	if err := validateListing(req.Name, req.Price); err != nil {
+		return 0, err
+	}
+	if err := u.validateCategory(ctx, req.CategoryID); err != nil {
+		return 0, err
+	}
+	if err := u.validateImages(ctx, req.SellerID, req.ImageIDs); err != nil {
+		return 0, err
+	}
 	item := &model.Item{
 		SellerID:    req.SellerID,
 		CategoryID:  req.CategoryID,
 		Name:        req.Name,
 		Price:       req.Price,
 		Description: req.Description,
-		ImageName:   req.ImageName,
+		ImageIDs:    req.ImageIDs,
+		Status:      model.ItemStatusOnSale,
+	}
+	if req.Draft {
+		item.Status = model.ItemStatusDraft
+	}
+	id, err := u.Repo.Insert(ctx, item)
+	if err != nil {
+		return 0, err
+	}
+
+	// 下書きは検索に出さない
+	if item.Status.Hidden() {
+		return id, nil
+	}
+	// 検索インデックスへの登録に失敗しても出品は成功させる (reindex-search で直せる)
+	// 出品はもう保存したので、クライアントが切断しても登録は最後までやる (WithoutCancel)
+	item.ID = id
+	if err := u.Search.Index(context.WithoutCancel(ctx), item); err != nil {
+		slog.WarnContext(ctx, "search index failed", "item_id", id, "error", err)
+	}
+	return id, nil
+}
+
+// 編集時のリクエストパラメータ (null の項目は変更しない)
+type UpdateItemReq struct {
+	CategoryID  *int    `json:"category_id"`
+	Name        *string `json:"name"`
+	Price       *int    `json:"price"`
+	Description *string `json:"description"`
+	ImageIDs    *[]int  `json:"image_ids"` // 指定したら画像をこの順番で置き換える
+}
+
+// UpdateItem: 出品内容を編集する。出品者本人が、売れる前の商品に対してだけできる
+// 価格を変えたときは履歴が残る
+func (u *ItemUsecase) UpdateItem(ctx context.Context, userID, itemID int, req UpdateItemReq) error {
+	item, err := u.editableItem(ctx, userID, itemID)
+	if err != nil {
+		return err
+	}
+
+	if req.CategoryID != nil && *req.CategoryID != item.CategoryID {
+		if err := u.validateCategory(ctx, *req.CategoryID); err != nil {
+			return err
+		}
+		item.CategoryID = *req.CategoryID
+	}
+	if req.Name != nil {
+		item.Name = *req.Name
+	}
+	if req.Price != nil {
+		item.Price = *req.Price
+	}
+	if req.Description != nil {
+		item.Description = *req.Description
+	}
+	if err := validateListing(item.Name, item.Price); err != nil {
+		return err
+	}
+	if req.ImageIDs != nil {
+		if err := u.validateImages(ctx, userID, *req.ImageIDs); err != nil {
+			return err
+		}
+		item.ImageIDs = *req.ImageIDs // [] なら画像をすべて外す
+	}
+
+	updated, err := u.Repo.UpdateListing(ctx, item)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		// 確認した後に売れた
+		return ErrItemNotEditable
+	}
+
+	if item.Status.Hidden() {
+		return nil
+	}
+	if err := u.Search.Index(context.WithoutCancel(ctx), item); err != nil {
+		slog.WarnContext(ctx, "search index failed", "item_id", item.ID, "error", err)
+	}
+	return nil
+}
+
+// WithdrawItem: 出品を取り下げる。一覧・検索には出なくなるが、出品者本人には見える
+func (u *ItemUsecase) WithdrawItem(ctx context.Context, userID, itemID int) error {
+	return u.ChangeStatus(ctx, userID, itemID, model.ItemStatusWithdrawn)
+}
+
+// ChangeStatus: 商品のステータスを変更する。変えられるかどうかは遷移表 (item_status.go) で決まる
+// 公開/非公開が切り替わったときは検索インデックスも更新する
+func (u *ItemUsecase) ChangeStatus(ctx context.Context, userID, itemID int, to model.ItemStatus) error {
+	if !to.Valid() {
+		return ErrInvalidItem.With("field", "status").With("reason", "unknown")
+	}
+	// TRADING には購入 (TransactionUsecase.Purchase) でしか移れない
+	// (ここで許すと、キャンセル後に再出品された商品を前の購入者が購入記録なしで取引中にできてしまう)
+	if to == model.ItemStatusTrading {
+		return ErrInvalidItem.With("field", "status").With("reason", "purchase_only")
+	}
+	item, err := u.Repo.FindByID(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return ErrItemNotFound
+	}
+
+	var from model.ItemStatus
+	err = u.Repo.ChangeStatus(ctx, itemID, userID, to, func(status model.ItemStatus, sellerID, buyerID int) error {
+		if status.Hidden() && sellerID != userID {
+			return ErrItemNotFound
+		}
+		from = status
+		return checkTransition(status, to, userID, sellerID, buyerID)
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case to.Hidden() && !from.Hidden():
+		if err := u.Search.Remove(context.WithoutCancel(ctx), itemID); err != nil {
+			slog.WarnContext(ctx, "search index removal failed", "item_id", itemID, "error", err)
+		}
+	case !to.Hidden() && from.Hidden():
+		item.Status = to
+		if err := u.Search.Index(context.WithoutCancel(ctx), item); err != nil {
+			slog.WarnContext(ctx, "search index failed", "item_id", itemID, "error", err)
+		}
+	}
+	return nil
+}
+
+// editableItem: 出品者本人の、編集できるステータス (下書き・販売中) の商品かを確認して返す
+func (u *ItemUsecase) editableItem(ctx context.Context, userID, itemID int) (*model.Item, error) {
+	item, err := u.Repo.FindByID(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || (item.Status.Hidden() && item.SellerID != userID) {
+		return nil, ErrItemNotFound
+	}
+	if item.SellerID != userID {
+		return nil, ErrNotItemSeller
+	}
+	if !item.Status.Editable() {
+		return nil, ErrItemNotEditable
+	}
+	return item, nil
+}
+
+// validateCategory: 商品は子カテゴリを持たないカテゴリ (葉) にだけ登録できる
+func (u *ItemUsecase) validateCategory(ctx context.Context, id int) error {
+	tree, err := loadCategoryTree(ctx, u.Categories)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.byID[id]; !ok {
+		return ErrInvalidItem.With("field", "category_id").With("reason", "unknown")
+	}
+	if !tree.isLeaf(id) {
+		return ErrInvalidItem.With("field", "category_id").With("reason", "not_leaf")
+	}
+	return nil
+}
+
+// validateImages: 商品に付ける画像が、出品者本人がアップロードしたものかを確認する
+func (u *ItemUsecase) validateImages(ctx context.Context, sellerID int, ids []int) error {
+	if len(ids) > maxItemImages {
+		return ErrInvalidItem.With("field", "image_ids").With("max_items", maxItemImages)
+	}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return ErrInvalidItem.With("field", "image_ids").With("reason", "duplicate").With("image_id", id)
+		}
+		seen[id] = true
+	}
+
+	images, err := u.Images.FindByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		if img.OwnerID == sellerID {
+			delete(seen, img.ID)
+		}
+	}
+	for id := range seen {
+		return ErrInvalidItem.With("field", "image_ids").With("reason", "unknown").With("image_id", id)
+	}
+	return nil
+}
+
+func validateListing(name string, price int) error {
+	if name == "" {
+		return ErrInvalidItem.With("field", "name").With("reason", "required")
+	}
+	if price <= 0 || price > maxItemPrice {
+		return ErrInvalidItem.With("field", "price").With("min", 1).With("max", maxItemPrice)
 	}
-	return u.Repo.Insert(item)
+	return nil
 }
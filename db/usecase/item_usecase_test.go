@@ -0,0 +1,402 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"db/model"
+	"db/search"
+)
+
+func TestItemUsecase_buildItemQuery(t *testing.T) {
+	u := NewItemUsecase(nil, nil, nil, nil)
+
+	cursor, err := encodeItemCursor(&model.ItemCursor{Sort: model.ItemSortNewest, CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), ID: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     ListItemsReq
+		wantErr error
+	}{
+		{"デフォルト", ListItemsReq{}, nil},
+		{"カーソルあり", ListItemsReq{Cursor: cursor}, nil},
+		{"並び順不正", ListItemsReq{Sort: "random"}, ErrInvalidQuery},
+		{"価格範囲が逆", ListItemsReq{MinPrice: 1000, MaxPrice: 500}, ErrInvalidQuery},
+		{"カーソル不正", ListItemsReq{Cursor: "not-a-cursor"}, ErrInvalidCursor},
+		{"並び順とカーソルが不一致", ListItemsReq{Sort: model.ItemSortPriceAsc, Cursor: cursor}, ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := u.buildItemQuery(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if q.Sort == "" || q.Limit != defaultItemsLimit {
+				t.Errorf("defaults not applied: %+v", q)
+			}
+			if tt.req.Cursor != "" && (q.After == nil || q.After.ID != 10) {
+				t.Errorf("cursor not decoded: %+v", q.After)
+			}
+		})
+	}
+}
+
+// MockItemRepo: メモリ上の商品 (DAO と同じく、編集・ステータス変更で価格とステータスの履歴を残す)
+type MockItemRepo struct {
+	items         []model.Item
+	buyers        map[int]int // 商品ID → 購入者
+	priceHistory  map[int][]model.PriceChange
+	statusHistory map[int][]model.ItemStatusChange
+}
+
+func (m *MockItemRepo) ListItems(ctx context.Context, q model.ItemQuery) ([]model.Item, *model.ItemCursor, error) {
+	var items []model.Item
+	for _, item := range m.items {
+		if (item.Status.Hidden() && !q.IncludeHidden) || (q.SellerID != 0 && item.SellerID != q.SellerID) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil, nil
+}
+func (m *MockItemRepo) CountItems(ctx context.Context, q model.ItemQuery) (int, error) {
+	items, _, err := m.ListItems(ctx, q)
+	return len(items), err
+}
+func (m *MockItemRepo) FindByID(ctx context.Context, id int) (*model.Item, error) {
+	for _, item := range m.items {
+		if item.ID == id {
+			return &item, nil
+		}
+	}
+	return nil, nil
+}
+func (m *MockItemRepo) FindByIDs(ctx context.Context, ids []int) ([]model.Item, error) {
+	var items []model.Item
+	for _, id := range ids {
+		if item, _ := m.FindByID(ctx, id); item != nil {
+			items = append(items, *item)
+		}
+	}
+	return items, nil
+}
+func (m *MockItemRepo) FindRelated(ctx context.Context, categoryID, excludeID, limit int) ([]model.Item, error) {
+	var items []model.Item
+	for _, item := range m.items {
+		if item.CategoryID == categoryID && item.ID != excludeID && !item.Status.Hidden() && len(items) < limit {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+func (m *MockItemRepo) FindSellerSummary(ctx context.Context, sellerID int) (*model.SellerSummary, error) {
+	return &model.SellerSummary{ID: sellerID, DisplayName: fmt.Sprintf("seller%d", sellerID)}, nil
+}
+func (m *MockItemRepo) Insert(ctx context.Context, item *model.Item) (int, error) {
+	item.ID = len(m.items) + 1
+	m.items = append(m.items, *item)
+	return item.ID, nil
+}
+func (m *MockItemRepo) UpdateListing(ctx context.Context, item *model.Item) (bool, error) {
+	for i, old := range m.items {
+		if old.ID != item.ID || old.SellerID != item.SellerID || !old.Status.Editable() {
+			continue
+		}
+		if old.Price != item.Price {
+			m.priceHistory[item.ID] = append(m.priceHistory[item.ID], model.PriceChange{OldPrice: old.Price, NewPrice: item.Price})
+		}
+		m.items[i] = *item
+		return true, nil
+	}
+	return false, nil
+}
+func (m *MockItemRepo) ChangeStatus(ctx context.Context, itemID, actorID int, to model.ItemStatus, check func(from model.ItemStatus, sellerID, buyerID int) error) error {
+	for i, item := range m.items {
+		if item.ID != itemID {
+			continue
+		}
+		if err := check(item.Status, item.SellerID, m.buyers[itemID]); err != nil {
+			return err
+		}
+		from := item.Status
+		m.items[i].Status = to
+		m.statusHistory[itemID] = append(m.statusHistory[itemID], model.ItemStatusChange{FromStatus: &from, ToStatus: to, ActorID: actorID})
+		return nil
+	}
+	return errors.New("no rows")
+}
+func (m *MockItemRepo) GetStatusHistory(ctx context.Context, itemID int) ([]model.ItemStatusChange, error) {
+	return m.statusHistory[itemID], nil
+}
+func (m *MockItemRepo) GetPriceHistory(ctx context.Context, itemID int) ([]model.PriceChange, error) {
+	return m.priceHistory[itemID], nil
+}
+
+const (
+	testSeller = 1
+	testBuyer  = 2
+	testOther  = 3
+)
+
+// 1 販売中 / 2 下書き / 3 取引中 (購入者 2) / 4 キャンセル後に再出品 (前の購入者 2) / 5 発送済み
+// カテゴリは newCategoryUsecase と同じ (5 少年漫画が葉)
+func newItemUsecase() (*ItemUsecase, *MockItemRepo) {
+	repo := &MockItemRepo{
+		items: []model.Item{
+			{ID: 1, SellerID: testSeller, CategoryID: 5, Name: "スマホケース", Price: 1000, Status: model.ItemStatusOnSale},
+			{ID: 2, SellerID: testSeller, CategoryID: 5, Name: "下書きの漫画", Price: 500, Status: model.ItemStatusDraft},
+			{ID: 3, SellerID: testSeller, CategoryID: 5, Name: "取引中の漫画", Price: 800, Status: model.ItemStatusTrading},
+			{ID: 4, SellerID: testSeller, CategoryID: 5, Name: "再出品の漫画", Price: 700, Status: model.ItemStatusOnSale},
+			{ID: 5, SellerID: testSeller, CategoryID: 5, Name: "発送済みの漫画", Price: 900, Status: model.ItemStatusShipped},
+		},
+		buyers:        map[int]int{3: testBuyer, 4: testBuyer, 5: testBuyer},
+		priceHistory:  map[int][]model.PriceChange{},
+		statusHistory: map[int][]model.ItemStatusChange{},
+	}
+	u := NewItemUsecase(repo, search.NewMemoryIndex(), &MockImageRepo{}, newCategoryUsecase().Repo)
+	return u, repo
+}
+
+func TestItemUsecase_ChangeStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   int
+		itemID  int
+		to      model.ItemStatus
+		wantErr error
+	}{
+		{"出品者が取り下げ", testSeller, 1, model.ItemStatusWithdrawn, nil},
+		{"出品者が発送", testSeller, 3, model.ItemStatusShipped, nil},
+		{"購入者が受け取り", testBuyer, 5, model.ItemStatusCompleted, nil},
+		// 購入 (Purchase) を通さずに取引中にはできない
+		{"前の購入者が再出品された商品を取引中にする", testBuyer, 4, model.ItemStatusTrading, ErrInvalidItem},
+		{"出品者でも取引中にはできない", testSeller, 1, model.ItemStatusTrading, ErrInvalidItem},
+		{"不明なステータス", testSeller, 1, "SOLD", ErrInvalidItem},
+		{"他人の下書きは見えない", testOther, 2, model.ItemStatusOnSale, ErrItemNotFound},
+		{"存在しない商品", testSeller, 99, model.ItemStatusWithdrawn, ErrItemNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newItemUsecase()
+			before, _ := repo.FindByID(context.Background(), tt.itemID)
+			err := u.ChangeStatus(context.Background(), tt.actor, tt.itemID, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			after, _ := repo.FindByID(context.Background(), tt.itemID)
+			if err != nil && before != nil && after.Status != before.Status {
+				t.Errorf("status changed to %s despite error", after.Status)
+			}
+			if err == nil && after.Status != tt.to {
+				t.Errorf("status = %s, want %s", after.Status, tt.to)
+			}
+		})
+	}
+}
+
+// MemoryIndex を通して検索する (関連度順・ページ送り・総数)
+func TestItemUsecase_SearchItems(t *testing.T) {
+	u, repo := newItemUsecase()
+	repo.items = []model.Item{
+		{ID: 1, SellerID: testSeller, Name: "スマホケース", Description: "iPhone用の手帳型ケース", Status: model.ItemStatusOnSale},
+		{ID: 2, SellerID: testSeller, Name: "ワンピース", Description: "夏用。スマホが入るポケット付き", Status: model.ItemStatusOnSale},
+		{ID: 3, SellerID: testSeller, Name: "ｽﾏﾎ スタンド", Description: "スマホを立てられます", Status: model.ItemStatusOnSale},
+		{ID: 4, SellerID: testSeller, Name: "小説", Description: "文庫本です", Status: model.ItemStatusOnSale},
+	}
+	for i := range repo.items {
+		if err := u.Search.Index(context.Background(), &repo.items[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := u.SearchItems(context.Background(), SearchItemsReq{Query: "すまほ", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.TotalCount != 3 {
+		t.Errorf("total = %d, want 3", first.TotalCount)
+	}
+	// 商品名と説明文の両方で一致する 3 が一番上、説明文だけの 2 は最後 (2ページ目)
+	if len(first.Items) != 2 || first.Items[0].ID != 3 || first.Items[1].ID != 1 {
+		t.Fatalf("first page = %+v, want items 3, 1", first.Items)
+	}
+	if first.Items[0].Score < first.Items[1].Score {
+		t.Errorf("not sorted by score: %v < %v", first.Items[0].Score, first.Items[1].Score)
+	}
+	if first.Items[1].Highlights.Name != "<em>スマホ</em>ケース" {
+		t.Errorf("highlight = %q", first.Items[1].Highlights.Name)
+	}
+	if first.NextOffset == nil || *first.NextOffset != 2 {
+		t.Fatalf("next offset = %v, want 2", first.NextOffset)
+	}
+
+	second, err := u.SearchItems(context.Background(), SearchItemsReq{Query: "すまほ", Limit: 2, Offset: *first.NextOffset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Items) != 1 || second.Items[0].ID != 2 || second.NextOffset != nil || second.TotalCount != 3 {
+		t.Errorf("second page = %+v (next %v, total %d), want only item 2 and no next page", second.Items, second.NextOffset, second.TotalCount)
+	}
+
+	// 取り下げた商品は検索から消える
+	if err := u.WithdrawItem(context.Background(), testSeller, 3); err != nil {
+		t.Fatal(err)
+	}
+	page, err := u.SearchItems(context.Background(), SearchItemsReq{Query: "すまほ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalCount != 2 {
+		t.Errorf("total after withdraw = %d, want 2", page.TotalCount)
+	}
+
+	if _, err := u.SearchItems(context.Background(), SearchItemsReq{Query: "  "}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("err = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestItemUsecase_UpdateItem(t *testing.T) {
+	price := func(p int) *int { return &p }
+	name := "新しい名前"
+	tests := []struct {
+		name        string
+		actor       int
+		itemID      int
+		req         UpdateItemReq
+		wantErr     error
+		wantHistory []model.PriceChange
+	}{
+		{"価格を変えると履歴が残る", testSeller, 1, UpdateItemReq{Price: price(800)}, nil, []model.PriceChange{{OldPrice: 1000, NewPrice: 800}}},
+		{"価格が同じなら履歴は残らない", testSeller, 1, UpdateItemReq{Name: &name, Price: price(1000)}, nil, nil},
+		{"下書きも編集できる", testSeller, 2, UpdateItemReq{Name: &name}, nil, nil},
+		{"出品者以外は編集できない", testOther, 1, UpdateItemReq{Price: price(1)}, ErrNotItemSeller, nil},
+		{"他人の下書きは見えない", testOther, 2, UpdateItemReq{Name: &name}, ErrItemNotFound, nil},
+		{"取引中は編集できない", testSeller, 3, UpdateItemReq{Price: price(1)}, ErrItemNotEditable, nil},
+		{"発送済み (売れた後) は編集できない", testSeller, 5, UpdateItemReq{Price: price(1)}, ErrItemNotEditable, nil},
+		{"価格の範囲外", testSeller, 1, UpdateItemReq{Price: price(0)}, ErrInvalidItem, nil},
+		{"子カテゴリを持つカテゴリには移せない", testSeller, 1, UpdateItemReq{CategoryID: price(4)}, ErrInvalidItem, nil},
+		{"存在しない商品", testSeller, 99, UpdateItemReq{Name: &name}, ErrItemNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newItemUsecase()
+			before, _ := repo.FindByID(context.Background(), tt.itemID)
+			err := u.UpdateItem(context.Background(), tt.actor, tt.itemID, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			after, _ := repo.FindByID(context.Background(), tt.itemID)
+			if err != nil && before != nil && !reflect.DeepEqual(after, before) {
+				t.Errorf("item changed despite error: %+v", after)
+			}
+			if err == nil && tt.req.Name != nil && after.Name != *tt.req.Name {
+				t.Errorf("name = %q, want %q", after.Name, *tt.req.Name)
+			}
+			if got := repo.priceHistory[tt.itemID]; !slices.Equal(got, tt.wantHistory) {
+				t.Errorf("price history = %+v, want %+v", got, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestItemUsecase_WithdrawItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   int
+		itemID  int
+		wantErr error
+	}{
+		{"出品者が取り下げ", testSeller, 1, nil},
+		{"出品者以外は取り下げできない", testOther, 1, ErrTransitionForbidden},
+		{"取引中は取り下げできない", testSeller, 3, ErrInvalidTransition},
+		{"存在しない商品", testSeller, 99, ErrItemNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newItemUsecase()
+			if err := u.WithdrawItem(context.Background(), tt.actor, tt.itemID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			// 一覧からは消えるが、出品者本人が自分の商品を一覧すると見える
+			page, err := u.GetItems(context.Background(), ListItemsReq{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range page.Items {
+				if item.ID == tt.itemID {
+					t.Errorf("withdrawn item %d is still listed", tt.itemID)
+				}
+			}
+			mine, err := u.GetItems(context.Background(), ListItemsReq{SellerID: testSeller, ViewerID: testSeller})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.ContainsFunc(mine.Items, func(item model.Item) bool { return item.ID == tt.itemID }) {
+				t.Errorf("seller can't see withdrawn item %d", tt.itemID)
+			}
+			if h := repo.statusHistory[tt.itemID]; len(h) != 1 || h[0].ToStatus != model.ItemStatusWithdrawn || h[0].ActorID != tt.actor {
+				t.Errorf("status history = %+v", h)
+			}
+		})
+	}
+}
+
+func TestItemUsecase_GetItemDetail(t *testing.T) {
+	tests := []struct {
+		name        string
+		itemID      int
+		viewer      int
+		wantErr     error
+		wantHistory bool // ステータスの履歴が付くか (出品者本人だけ)
+	}{
+		{"未ログインで販売中の商品", 1, 0, nil, false},
+		{"出品者本人", 1, testSeller, nil, true},
+		{"出品者本人は下書きも見える", 2, testSeller, nil, true},
+		{"他人の下書きは見えない", 2, testOther, ErrItemNotFound, false},
+		{"未ログインで下書きは見えない", 2, 0, ErrItemNotFound, false},
+		{"存在しない商品", 99, 0, ErrItemNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, repo := newItemUsecase()
+			repo.statusHistory[tt.itemID] = []model.ItemStatusChange{{ToStatus: model.ItemStatusOnSale, ActorID: testSeller}}
+			detail, err := u.GetItemDetail(context.Background(), tt.itemID, tt.viewer)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if detail.ID != tt.itemID || detail.Seller.ID != testSeller || detail.Seller.DisplayName != "seller1" {
+				t.Errorf("item/seller = %d / %+v", detail.ID, detail.Seller)
+			}
+			// 空でも null ではなく [] で返す
+			if detail.Images == nil || detail.PriceHistory == nil || detail.RelatedItems == nil {
+				t.Errorf("nil slices: %+v", detail)
+			}
+			for _, related := range detail.RelatedItems {
+				if related.ID == tt.itemID || related.Status.Hidden() {
+					t.Errorf("unexpected related item: %+v", related)
+				}
+			}
+			if got := detail.StatusHistory != nil; got != tt.wantHistory {
+				t.Errorf("status history = %+v, want included %v", detail.StatusHistory, tt.wantHistory)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package usecase
 
 import (
+	"context"
+	"db/apperr"
 	"db/dao"
 	"db/model"
-	"fmt"
 )
 
+var ErrInvalidMessage = apperr.New(apperr.Validation, "invalid_message", "invalid message")
+
 type MessageUsecase struct {
 	Dao *dao.MessageDao
 }
@@ -16,15 +19,18 @@ func NewMessageUsecase(d *dao.MessageDao) *MessageUsecase {
 
 type SendMessageReq struct {
 	ItemID     int    `json:"item_id"` // 👈 追加
-	SenderID   int    `json:"sender_id"`
+	SenderID   int    `json:"-"`       // ログイン中のユーザー (トークンから設定)
 	ReceiverID int    `json:"receiver_id"`
 	Content    string `json:"content"`
 }
 
 // SendMessage: メッセージ送信
-func (u *MessageUsecase) SendMessage(req SendMessageReq) error {
+func (u *MessageUsecase) SendMessage(ctx context.Context, req SendMessageReq) error {
 	if req.Content == "" {
-		return fmt.Errorf("message content is empty")
+		return ErrInvalidMessage.With("field", "content").With("reason", "required")
+	}
+	if req.ReceiverID == 0 {
+		return ErrInvalidMessage.With("field", "receiver_id").With("reason", "invalid")
 	}
 	msg := &model.Message{
 		ItemID:     req.ItemID, // 👈 追加
@@ -32,14 +38,20 @@ func (u *MessageUsecase) SendMessage(req SendMessageReq) error {
 		ReceiverID: req.ReceiverID,
 		Content:    req.Content,
 	}
-	return u.Dao.Create(msg)
+	return u.Dao.Create(ctx, msg)
 }
 
 // GetHistory: 履歴取得 (引数に itemID を追加)
-func (u *MessageUsecase) GetHistory(itemID, user1, user2 int) ([]model.Message, error) {
-	return u.Dao.GetConversation(itemID, user1, user2)
+func (u *MessageUsecase) GetHistory(ctx context.Context, itemID, user1, user2 int) ([]model.Message, error) {
+	if itemID == 0 {
+		return nil, ErrInvalidQuery.With("field", "item_id").With("reason", "required")
+	}
+	if user2 == 0 {
+		return nil, ErrInvalidQuery.With("field", "partner_id").With("reason", "required")
+	}
+	return u.Dao.GetConversation(ctx, itemID, user1, user2)
 }
 
-func (u *MessageUsecase) GetNotifications(userID int) ([]model.Notification, error) {
-	return u.Dao.GetNotifications(userID)
+func (u *MessageUsecase) GetNotifications(ctx context.Context, userID int) ([]model.Notification, error) {
+	return u.Dao.GetNotifications(ctx, userID)
 }
@@ -1,18 +1,93 @@
 package usecase
 
-import "db/model"
+import (
+	"context"
+	"time"
+
+	"db/model"
+)
 
 type UserRepository interface {
-	FindByName(name string) ([]model.User, error)
-	Insert(user *model.User) (int, error)
+	// 完全一致の名前検索 (ログイン用)。認証情報を含むので外部には返さないこと
+	FindByName(ctx context.Context, name string) ([]model.User, error)
+	// 公開プロフィール。FindPublicByID は見つからなければ nil
+	FindPublicByID(ctx context.Context, id int) (*model.PublicUser, error)
+	SearchPublic(ctx context.Context, prefix string, limit, offset int) ([]model.PublicUser, error)
+	// 見つからなければ nil
+	FindByID(ctx context.Context, id int) (*model.User, error)
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	Insert(ctx context.Context, user *model.User) (int, error)
+	UpdateProfile(ctx context.Context, user *model.User) error
+	SetEmail(ctx context.Context, id int, email string, verified bool) error
+	UpdatePassword(ctx context.Context, id int, password string) error
+	// 外部ID (Google の sub など) との紐付け。見つからなければ nil
+	FindByIdentity(ctx context.Context, provider, subject string) (*model.User, error)
+	LinkIdentity(ctx context.Context, identity *model.Identity) error
+	// ユーザーの作成と外部IDの紐付けを1つのトランザクションで行う (identity.UserID は無視する)
+	InsertWithIdentity(ctx context.Context, user *model.User, identity *model.Identity) (int, error)
+	ListIdentities(ctx context.Context, userID int) ([]model.Identity, error)
+	// パスワードが平文のまま残っているユーザー (移行状況の確認用)
+	FindPlaintextPasswordUsers(ctx context.Context) ([]model.User, error)
 }
 
 type ItemRepository interface {
-	GetItems() ([]model.Item, error)
-	Insert(item *model.Item) (int, error)
+	// 条件に合う商品を q.Limit 件まで。続きがあれば次のページのカーソルも返す
+	ListItems(ctx context.Context, q model.ItemQuery) ([]model.Item, *model.ItemCursor, error)
+	CountItems(ctx context.Context, q model.ItemQuery) (int, error)
+	// 見つからなければ nil
+	FindByID(ctx context.Context, id int) (*model.Item, error)
+	// ids の順番で返す (見つからないIDは飛ばす)
+	FindByIDs(ctx context.Context, ids []int) ([]model.Item, error)
+	FindRelated(ctx context.Context, categoryID, excludeID, limit int) ([]model.Item, error)
+	FindSellerSummary(ctx context.Context, sellerID int) (*model.SellerSummary, error)
+	Insert(ctx context.Context, item *model.Item) (int, error)
+	// 出品者本人の編集できるステータスの商品でなければ変更せず false を返す
+	UpdateListing(ctx context.Context, item *model.Item) (bool, error)
+	// 行ロックを取った上で check (遷移表) を通ったときだけステータスを変え、履歴を残す
+	ChangeStatus(ctx context.Context, itemID, actorID int, to model.ItemStatus, check func(from model.ItemStatus, sellerID, buyerID int) error) error
+	GetStatusHistory(ctx context.Context, itemID int) ([]model.ItemStatusChange, error)
+	GetPriceHistory(ctx context.Context, itemID int) ([]model.PriceChange, error)
+}
+
+type ImageRepository interface {
+	Insert(ctx context.Context, img *model.Image) (int, error)
+	// 見つからなければ nil
+	FindByID(ctx context.Context, id int) (*model.Image, error)
+	// 見つからないIDは飛ばす (順番は不定)
+	FindByIDs(ctx context.Context, ids []int) ([]model.Image, error)
+	// 商品の画像 (表示順)
+	FindByItem(ctx context.Context, itemID int) ([]model.Image, error)
+	// 指定したサイズの画像 (形式違い)。サイズ違いが無い古い画像なら空
+	FindVariants(ctx context.Context, imageID int, size model.ImageSize) ([]model.ImageVariant, error)
+}
+
+type CategoryRepository interface {
+	// 全カテゴリ (表示順)
+	List(ctx context.Context) ([]model.Category, error)
+	Insert(ctx context.Context, c *model.Category) (int, error)
+	Update(ctx context.Context, c *model.Category) error
+	Delete(ctx context.Context, id int) error
+	CountItems(ctx context.Context, id int) (int, error)
+}
+
+// ItemSearchIndex: 商品のキーワード検索 (本番は MySQL FULLTEXT、テストはメモリ上のインデックス)
+// terms は search.Terms で正規化済みの語。すべての語を含む商品を関連度順に返す
+type ItemSearchIndex interface {
+	Index(ctx context.Context, item *model.Item) error
+	Remove(ctx context.Context, itemID int) error
+	Search(ctx context.Context, terms []string, limit, offset int) ([]model.SearchHit, int, error)
 }
 
 type TransactionRepository interface {
-	// 購入処理 (エラーなしなら購入完了)
-	Purchase(itemID int, buyerID int) error
+	// 購入処理 (true でエラーなしなら購入完了)。check がエラーを返したら何もしない
+	// 商品が存在しなければ何もせず false を返す
+	Purchase(ctx context.Context, itemID int, buyerID int, check func(from model.ItemStatus, sellerID int) error) (bool, error)
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, s *model.Session) error
+	FindByID(ctx context.Context, id string) (*model.Session, error)
+	FindByRefreshTokenHash(ctx context.Context, hash string) (*model.Session, error)
+	RotateRefreshToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id string) error
 }
@@ -0,0 +1,13 @@
+package usecase
+
+import (
+	"db/dao"
+	"db/search"
+)
+
+// 検索インデックスの実装がインターフェースからずれたらコンパイルで気付けるように
+// (search は usecase を import できないので、ここで確認する)
+var (
+	_ ItemSearchIndex = (*search.MemoryIndex)(nil)
+	_ ItemSearchIndex = (*dao.ItemSearchDao)(nil)
+)
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"db/apperr"
+	"db/auth"
+	"db/model"
+)
+
+var (
+	ErrUnauthenticated = apperr.New(apperr.Unauthorized, "unauthenticated", "unauthenticated")
+	ErrSessionExpired  = apperr.New(apperr.Unauthorized, "session_expired", "session expired")
+)
+
+type SessionUsecase struct {
+	Repo   SessionRepository
+	Tokens *auth.TokenIssuer
+}
+
+func NewSessionUsecase(repo SessionRepository, tokens *auth.TokenIssuer) *SessionUsecase {
+	return &SessionUsecase{Repo: repo, Tokens: tokens}
+}
+
+// TokenPair: ログイン・リフレッシュ時にフロントへ返すトークン一式
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // アクセストークンの残り秒数
+}
+
+// StartSession: ログイン成功後に呼ぶ。セッションを作ってトークンを発行する
+func (u *SessionUsecase) StartSession(ctx context.Context, userID int) (*TokenPair, error) {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &model.Session{
+		ID:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: auth.HashRefreshToken(refreshToken),
+		ExpiresAt:        time.Now().Add(u.Tokens.RefreshTTL),
+	}
+	if err := u.Repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return u.issue(userID, sessionID, refreshToken)
+}
+
+type RefreshReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh: リフレッシュトークンを使って新しいトークン一式を発行する
+// リフレッシュトークンは使い捨て (毎回新しいものに差し替える)
+func (u *SessionUsecase) Refresh(ctx context.Context, req RefreshReq) (*TokenPair, error) {
+	if req.RefreshToken == "" {
+		return nil, ErrUnauthenticated
+	}
+	oldHash := auth.HashRefreshToken(req.RefreshToken)
+	session, err := u.Repo.FindByRefreshTokenHash(ctx, oldHash)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrUnauthenticated
+	}
+	if !session.Active(time.Now()) {
+		return nil, ErrSessionExpired
+	}
+
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(u.Tokens.RefreshTTL)
+	if err := u.Repo.RotateRefreshToken(ctx, session.ID, oldHash, auth.HashRefreshToken(refreshToken), expiresAt); err != nil {
+		return nil, ErrUnauthenticated.Wrap(err)
+	}
+	return u.issue(session.UserID, session.ID, refreshToken)
+}
+
+// Authenticate: アクセストークンを検証し、セッションが生きていればユーザーIDを返す
+func (u *SessionUsecase) Authenticate(ctx context.Context, accessToken string) (int, string, error) {
+	userID, sessionID, err := u.Tokens.ParseAccessToken(accessToken)
+	if err != nil {
+		return 0, "", err
+	}
+
+	// ログアウト済みのセッションのトークンは、期限内でも拒否する
+	session, err := u.Repo.FindByID(ctx, sessionID)
+	if err != nil {
+		return 0, "", err
+	}
+	if session == nil || session.UserID != userID || !session.Active(time.Now()) {
+		return 0, "", ErrSessionExpired
+	}
+	return userID, sessionID, nil
+}
+
+// Logout: セッションを失効させる
+func (u *SessionUsecase) Logout(ctx context.Context, sessionID string) error {
+	return u.Repo.Revoke(ctx, sessionID)
+}
+
+func (u *SessionUsecase) issue(userID int, sessionID, refreshToken string) (*TokenPair, error) {
+	accessToken, expiresAt, err := u.Tokens.IssueAccessToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
+	}, nil
+}
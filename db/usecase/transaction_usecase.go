@@ -1,6 +1,15 @@
 package usecase
 
-import "fmt"
+import (
+	"context"
+	"db/apperr"
+	"db/model"
+)
+
+var (
+	ErrInvalidPurchase  = apperr.New(apperr.Validation, "invalid_purchase", "item_id is required")
+	ErrCannotBuyOwnItem = apperr.New(apperr.Forbidden, "cannot_buy_own_item", "you cannot buy your own item")
+)
 
 type TransactionUsecase struct {
 	Repo TransactionRepository
@@ -12,12 +21,28 @@ func NewTransactionUsecase(repo TransactionRepository) *TransactionUsecase {
 
 type PurchaseReq struct {
 	ItemID  int `json:"item_id"`
-	BuyerID int `json:"buyer_id"`
+	BuyerID int `json:"-"` // ログイン中のユーザー (トークンから設定)
 }
 
-func (u *TransactionUsecase) Purchase(req PurchaseReq) error {
+func (u *TransactionUsecase) Purchase(ctx context.Context, req PurchaseReq) error {
 	if req.ItemID == 0 || req.BuyerID == 0 {
-		return fmt.Errorf("invalid request")
+		return ErrInvalidPurchase
+	}
+	// 買えるかどうかは遷移表で判定する (購入しようとしている人を購入者として扱う)
+	found, err := u.Repo.Purchase(ctx, req.ItemID, req.BuyerID, func(from model.ItemStatus, sellerID int) error {
+		if sellerID == req.BuyerID {
+			return ErrCannotBuyOwnItem
+		}
+		if from.Hidden() {
+			return ErrItemNotFound
+		}
+		return checkTransition(from, model.ItemStatusTrading, req.BuyerID, sellerID, req.BuyerID)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrItemNotFound
 	}
-	return u.Repo.Purchase(req.ItemID, req.BuyerID)
+	return nil
 }
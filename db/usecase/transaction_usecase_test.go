@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"db/model"
+)
+
+// MockTransactionRepo: DAO と同じく、商品がなければ check を呼ばずに false を返す
+type MockTransactionRepo struct {
+	items     map[int]model.Item
+	purchased []int
+}
+
+func (m *MockTransactionRepo) Purchase(ctx context.Context, itemID int, buyerID int, check func(from model.ItemStatus, sellerID int) error) (bool, error) {
+	item, ok := m.items[itemID]
+	if !ok {
+		return false, nil
+	}
+	if err := check(item.Status, item.SellerID); err != nil {
+		return true, err
+	}
+	m.purchased = append(m.purchased, itemID)
+	return true, nil
+}
+
+func TestTransactionUsecase_Purchase(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     PurchaseReq
+		wantErr error
+	}{
+		{"購入", PurchaseReq{ItemID: 1, BuyerID: testBuyer}, nil},
+		{"存在しない商品は 404", PurchaseReq{ItemID: 99, BuyerID: testBuyer}, ErrItemNotFound},
+		{"下書きは見えない", PurchaseReq{ItemID: 2, BuyerID: testBuyer}, ErrItemNotFound},
+		{"自分の商品は買えない", PurchaseReq{ItemID: 1, BuyerID: testSeller}, ErrCannotBuyOwnItem},
+		{"取引中は買えない", PurchaseReq{ItemID: 3, BuyerID: testOther}, ErrInvalidTransition},
+		{"商品の指定なし", PurchaseReq{BuyerID: testBuyer}, ErrInvalidPurchase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockTransactionRepo{items: map[int]model.Item{
+				1: {ID: 1, SellerID: testSeller, Status: model.ItemStatusOnSale},
+				2: {ID: 2, SellerID: testSeller, Status: model.ItemStatusDraft},
+				3: {ID: 3, SellerID: testSeller, Status: model.ItemStatusTrading},
+			}}
+			err := NewTransactionUsecase(repo).Purchase(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if (err == nil) != (len(repo.purchased) == 1) {
+				t.Errorf("purchased = %v", repo.purchased)
+			}
+		})
+	}
+}
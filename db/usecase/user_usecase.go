@@ -1,42 +1,136 @@
 package usecase
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
 
+	"db/apperr"
+	"db/auth"
 	"db/model"
 )
 
+// IDTokenVerifier: ソーシャルログインの ID トークン検証 (テストでは差し替える)
+type IDTokenVerifier interface {
+	Verify(ctx context.Context, idToken string) (*auth.IDTokenClaims, error)
+}
+
+var (
+	ErrUserNotFound  = apperr.New(apperr.NotFound, "user_not_found", "user not found")
+	ErrInvalidUser   = apperr.New(apperr.Validation, "invalid_user", "invalid user")
+	ErrEmailInUse    = apperr.New(apperr.Conflict, "email_in_use", "email already registered")
+	ErrIdentityInUse = apperr.New(apperr.Conflict, "identity_in_use", "this account is already linked to another user")
+	// ユーザーがいないのかパスワードが違うのかは区別しない (名前があるかどうかを探れないように)
+	ErrInvalidCredentials = apperr.New(apperr.Unauthorized, "invalid_credentials", "invalid name or password")
+)
+
 type UserUsecase struct {
-	Repo UserRepository
+	Repo     UserRepository
+	Hasher   *auth.PasswordHasher
+	IDTokens IDTokenVerifier
+}
+
+func NewUserUsecase(repo UserRepository, hasher *auth.PasswordHasher, idTokens IDTokenVerifier) *UserUsecase {
+	return &UserUsecase{Repo: repo, Hasher: hasher, IDTokens: idTokens}
 }
 
-func NewUserUsecase(repo UserRepository) *UserUsecase {
-	return &UserUsecase{Repo: repo}
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// UserSearchResult: ユーザー検索の結果。NextOffset が null なら最後のページ
+type UserSearchResult struct {
+	Users      []model.PublicUser `json:"users"`
+	NextOffset *int               `json:"next_offset"`
 }
 
-func (u *UserUsecase) SearchUser(name string) ([]model.User, error) {
-	if name == "" {
-		return nil, fmt.Errorf("name is empty")
+// SearchUsers: 表示名の前方一致でユーザーを検索する
+func (u *UserUsecase) SearchUsers(ctx context.Context, query string, limit, offset int) (*UserSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrInvalidQuery.With("field", "q").With("reason", "required")
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
 	}
-	return u.Repo.FindByName(name)
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 1件多く取って、次のページがあるか判定する
+	users, err := u.Repo.SearchPublic(ctx, query, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+	result := &UserSearchResult{Users: users}
+	if len(users) > limit {
+		result.Users = users[:limit]
+		next := offset + limit
+		result.NextOffset = &next
+	}
+	if result.Users == nil {
+		result.Users = []model.PublicUser{}
+	}
+	return result, nil
+}
+
+// GetPublicProfile: 他のユーザーに見せるプロフィール
+func (u *UserUsecase) GetPublicProfile(ctx context.Context, id int) (*model.PublicUser, error) {
+	user, err := u.Repo.FindPublicByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
 }
 
 type RegisterUserReq struct {
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Name        string `json:"name"`
+	Password    string `json:"password"`
+	Email       string `json:"email"`        // 任意
+	DisplayName string `json:"display_name"` // 任意 (省略時はログイン名)
 }
 
-func (u *UserUsecase) RegisterUser(req RegisterUserReq) (int, error) {
+func (u *UserUsecase) RegisterUser(ctx context.Context, req RegisterUserReq) (int, error) {
 	if err := u.validateRegisterRequest(req); err != nil {
 		return 0, err
 	}
 
+	if req.Email != "" {
+		existing, err := u.Repo.FindByEmail(ctx, req.Email)
+		if err != nil {
+			return 0, err
+		}
+		if existing != nil {
+			return 0, ErrEmailInUse
+		}
+	}
+
+	hash, err := u.Hasher.Hash(req.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	displayName := req.DisplayName
+	if displayName == "" {
+		displayName = req.Name
+	}
 	user := &model.User{
-		Name:     req.Name,
-		Password: req.Password,
+		Name:        req.Name,
+		Password:    hash,
+		Email:       req.Email,
+		DisplayName: displayName,
 	}
 
-	id, err := u.Repo.Insert(user)
+	id, err := u.Repo.Insert(ctx, user)
 	if err != nil {
 		return 0, err
 	}
@@ -45,13 +139,29 @@ func (u *UserUsecase) RegisterUser(req RegisterUserReq) (int, error) {
 
 func (u *UserUsecase) validateRegisterRequest(req RegisterUserReq) error {
 	if req.Name == "" {
-		return fmt.Errorf("invalid name: empty")
+		return ErrInvalidUser.With("field", "name").With("reason", "required")
 	}
 	if len(req.Name) > 50 {
-		return fmt.Errorf("invalid name: too long")
+		return ErrInvalidUser.With("field", "name").With("max_length", 50)
+	}
+	// "google:<sub>" のようなソーシャルログイン用の名前と衝突しないように
+	if strings.Contains(req.Name, ":") {
+		return ErrInvalidUser.With("field", "name").With("reason", "invalid_character")
+	}
+	if req.Email != "" {
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			return ErrInvalidUser.With("field", "email").With("reason", "invalid_format")
+		}
+	}
+	if utf8.RuneCountInString(req.DisplayName) > 50 {
+		return ErrInvalidUser.With("field", "display_name").With("max_length", 50)
 	}
 	if len(req.Password) < 4 {
-		return fmt.Errorf("invalid password: password too short; password must have at least 4 characters")
+		return ErrInvalidUser.With("field", "password").With("min_length", 4)
+	}
+	// bcrypt は 72 バイトを超える部分を扱えない
+	if len(req.Password) > 72 {
+		return ErrInvalidUser.With("field", "password").With("max_length", 72)
 	}
 	return nil
 }
@@ -62,59 +172,229 @@ type LoginReq struct {
 }
 
 // Login: 名前で検索し、パスワードが一致するか確認する
-func (u *UserUsecase) Login(req LoginReq) (int, error) {
+func (u *UserUsecase) Login(ctx context.Context, req LoginReq) (int, error) {
 	// 1. 名前でユーザーを探す
-	users, err := u.Repo.FindByName(req.Name)
+	users, err := u.Repo.FindByName(ctx, req.Name)
 	if err != nil {
 		return 0, err
 	}
 	if len(users) == 0 {
-		return 0, fmt.Errorf("user not found")
+		return 0, ErrInvalidCredentials
 	}
 
-	// 2. パスワード照合 (ハッカソン用: 平文比較)
+	// 2. パスワード照合 (bcrypt。平文で残っている旧データとも照合できる)
 	// FindByNameはリストを返すので、先頭のユーザーを使います
 	targetUser := users[0]
-	if targetUser.Password != req.Password {
-		return 0, fmt.Errorf("invalid password")
+	ok, needsRehash := u.Hasher.Verify(targetUser.Password, req.Password)
+	if !ok {
+		return 0, ErrInvalidCredentials
+	}
+
+	// 3. 平文や古いコストのまま保存されていたら、この機会にハッシュし直す
+	// 失敗してもログインは成功させる (次回ログイン時に再挑戦)
+	if needsRehash {
+		if err := u.rehashPassword(ctx, targetUser.ID, req.Password); err != nil {
+			slog.WarnContext(ctx, "password rehash failed", "user_id", targetUser.ID, "error", err)
+		}
 	}
 
 	return targetUser.ID, nil
 }
 
-// ▼▼▼ 追加: ソーシャルログイン用リクエスト型 ▼▼▼
+func (u *UserUsecase) rehashPassword(ctx context.Context, id int, password string) error {
+	hash, err := u.Hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return u.Repo.UpdatePassword(ctx, id, hash)
+}
+
+// PlaintextPasswordUsers: パスワードが平文のまま残っているユーザーの一覧
+// 該当ユーザーは次にログインに成功した時点でハッシュ化される
+func (u *UserUsecase) PlaintextPasswordUsers(ctx context.Context) ([]model.User, error) {
+	return u.Repo.FindPlaintextPasswordUsers(ctx)
+}
+
+// ソーシャルログイン用リクエスト型
+// フロントは Google から受け取った ID トークンをそのまま送る
 type SocialLoginReq struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	IDToken string `json:"id_token"`
 }
 
-// ▼▼▼ 追加: ソーシャルログインのロジック ▼▼▼
-func (u *UserUsecase) SocialLogin(req SocialLoginReq) (int, string, error) {
-	// 1. そのメールアドレス(Nameカラムに保存)のユーザーがいるか確認
-	users, err := u.Repo.FindByName(req.Email)
+const providerGoogle = "google"
+
+// SocialLogin: ID トークンを検証し、sub に紐付いたユーザーとしてログインする
+// 紐付いたユーザーがいなければ作成する
+func (u *UserUsecase) SocialLogin(ctx context.Context, req SocialLoginReq) (int, string, error) {
+	// 1. 署名・iss・aud・exp を検証
+	claims, err := u.verifyIDToken(ctx, req.IDToken)
+	if err != nil {
+		return 0, "", err
+	}
+
+	// 2. 既に sub が紐付いているユーザーがいれば、そのユーザーとしてログイン
+	user, err := u.Repo.FindByIdentity(ctx, providerGoogle, claims.Subject)
 	if err != nil {
 		return 0, "", err
 	}
+	if user != nil {
+		return user.ID, user.DisplayName, nil
+	}
 
-	// 2. 既にいるなら、そのユーザー情報を返す
-	if len(users) > 0 {
-		return users[0].ID, users[0].Name, nil
+	// 3. 同じメアドのユーザーがいれば紐付ける
+	// どちらのメアドも確認済みの場合に限る (未確認のメアドで登録した他人のアカウントに入らないように)
+	// 未確認のアカウントは、パスワードでログインしてから紐付けてもらう
+	email := ""
+	if claims.EmailVerified {
+		email = claims.Email
+	}
+	if email != "" {
+		existing, err := u.Repo.FindByEmail(ctx, email)
+		if err != nil {
+			return 0, "", err
+		}
+		if existing != nil && !existing.EmailVerified {
+			return 0, "", ErrEmailInUse
+		}
+		if existing != nil {
+			if err := u.linkIdentity(ctx, existing.ID, claims); err != nil {
+				return 0, "", err
+			}
+			return existing.ID, existing.DisplayName, nil
+		}
 	}
 
-	// 3. いないなら、新規作成する
-	// ハッカソン仕様: Nameカラムにメアドを入れ、パスワードはダミーを入れる
+	// 4. いないなら、新規作成する
+	// ログイン名はパスワードユーザーと衝突しない "google:<sub>" にする
+	// パスワードは空にしておき、パスワードログインはできないようにする
+	displayName := claims.Name
+	if displayName == "" {
+		displayName = strings.Split(claims.Email, "@")[0]
+	}
 	newUser := &model.User{
-		Name:     req.Email,
-		Password: "google_login", // ソーシャルログイン用のダミーパスワード
+		Name:          providerGoogle + ":" + claims.Subject,
+		Password:      "",
+		Email:         email,
+		EmailVerified: email != "",
+		DisplayName:   displayName,
+		AvatarURL:     claims.Picture,
 	}
-
-	id, err := u.Repo.Insert(newUser)
+	// 紐付けに失敗したときにユーザーだけ残らないよう、まとめて作る
+	// (残ると次のログインでもう1人作ってしまう)
+	id, err := u.Repo.InsertWithIdentity(ctx, newUser, googleIdentity(0, claims))
 	if err != nil {
 		return 0, "", err
 	}
+	return id, displayName, nil
+}
+
+// LinkSocialLogin: ログイン中のユーザーに Google アカウントを紐付ける
+// 以後、パスワードと Google のどちらでもログインできる
+func (u *UserUsecase) LinkSocialLogin(ctx context.Context, userID int, req SocialLoginReq) error {
+	claims, err := u.verifyIDToken(ctx, req.IDToken)
+	if err != nil {
+		return err
+	}
+
+	linked, err := u.Repo.FindByIdentity(ctx, providerGoogle, claims.Subject)
+	if err != nil {
+		return err
+	}
+	if linked != nil {
+		if linked.ID == userID {
+			return nil // 紐付け済み
+		}
+		return ErrIdentityInUse
+	}
+
+	user, err := u.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err := u.linkIdentity(ctx, userID, claims); err != nil {
+		return err
+	}
+
+	// Google で確認済みのメアドと一致すれば、アカウントのメアドも確認済みにする
+	// メアド未登録なら Google のメアドを登録する
+	if claims.EmailVerified && claims.Email != "" && !user.EmailVerified {
+		if user.Email == claims.Email || user.Email == "" {
+			return u.Repo.SetEmail(ctx, userID, claims.Email, true)
+		}
+	}
+	return nil
+}
+
+func (u *UserUsecase) verifyIDToken(ctx context.Context, idToken string) (*auth.IDTokenClaims, error) {
+	if idToken == "" {
+		return nil, auth.ErrTokenInvalid.With("field", "id_token").With("reason", "required")
+	}
+	return u.IDTokens.Verify(ctx, idToken)
+}
+
+func (u *UserUsecase) linkIdentity(ctx context.Context, userID int, claims *auth.IDTokenClaims) error {
+	return u.Repo.LinkIdentity(ctx, googleIdentity(userID, claims))
+}
+
+func googleIdentity(userID int, claims *auth.IDTokenClaims) *model.Identity {
+	return &model.Identity{
+		UserID:   userID,
+		Provider: providerGoogle,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+}
+
+// Profile: 自分のプロフィール (紐付いている外部IDも含む)
+type Profile struct {
+	model.User
+	Identities []model.Identity `json:"identities"`
+}
 
-	// 表示名はリクエストのName(Googleの名前)を返したいが、
-	// DB上はEmailで管理しているので、ここでは便宜上EmailをNameとして扱うか、
-	// フロントの表示用に req.Name を返す設計にします。
-	return id, req.Name, nil
+func (u *UserUsecase) GetProfile(ctx context.Context, userID int) (*Profile, error) {
+	user, err := u.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	identities, err := u.Repo.ListIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if identities == nil {
+		identities = []model.Identity{}
+	}
+	return &Profile{User: *user, Identities: identities}, nil
+}
+
+type UpdateProfileReq struct {
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Bio         string `json:"bio"`
+}
+
+func (u *UserUsecase) UpdateProfile(ctx context.Context, userID int, req UpdateProfileReq) error {
+	if req.DisplayName == "" {
+		return ErrInvalidUser.With("field", "display_name").With("reason", "required")
+	}
+	if utf8.RuneCountInString(req.DisplayName) > 50 {
+		return ErrInvalidUser.With("field", "display_name").With("max_length", 50)
+	}
+	if len(req.AvatarURL) > 512 {
+		return ErrInvalidUser.With("field", "avatar_url").With("max_length", 512)
+	}
+	if utf8.RuneCountInString(req.Bio) > 1000 {
+		return ErrInvalidUser.With("field", "bio").With("max_length", 1000)
+	}
+	return u.Repo.UpdateProfile(ctx, &model.User{
+		ID:          userID,
+		DisplayName: req.DisplayName,
+		AvatarURL:   req.AvatarURL,
+		Bio:         req.Bio,
+	})
 }
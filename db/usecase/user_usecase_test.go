@@ -1,17 +1,91 @@
 package usecase
 
 import (
+	"context"
+	"db/auth"
 	"db/model"
+	"errors"
 	"testing"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
-type MockRepo struct{}
+type MockRepo struct {
+	users      []model.User
+	updated    map[int]string
+	identities []model.Identity
+	insertErr  error // InsertWithIdentity を失敗させる (何も保存しない)
+}
+
+func (m *MockRepo) FindByName(ctx context.Context, name string) ([]model.User, error) {
+	var found []model.User
+	for _, u := range m.users {
+		if u.Name == name {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
+func (m *MockRepo) FindByID(ctx context.Context, id int) (*model.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
+func (m *MockRepo) FindPublicByID(ctx context.Context, id int) (*model.PublicUser, error) {
+	return nil, nil
+}
+func (m *MockRepo) SearchPublic(ctx context.Context, prefix string, limit, offset int) ([]model.PublicUser, error) {
+	return nil, nil
+}
+func (m *MockRepo) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return nil, nil
+}
+func (m *MockRepo) Insert(ctx context.Context, user *model.User) (int, error) { return 1, nil }
+func (m *MockRepo) UpdateProfile(ctx context.Context, user *model.User) error { return nil }
+func (m *MockRepo) SetEmail(ctx context.Context, id int, email string, verified bool) error {
+	return nil
+}
+func (m *MockRepo) UpdatePassword(ctx context.Context, id int, password string) error {
+	if m.updated == nil {
+		m.updated = map[int]string{}
+	}
+	m.updated[id] = password
+	return nil
+}
+func (m *MockRepo) FindPlaintextPasswordUsers(ctx context.Context) ([]model.User, error) {
+	return nil, nil
+}
+func (m *MockRepo) FindByIdentity(ctx context.Context, provider, subject string) (*model.User, error) {
+	for _, i := range m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return m.FindByID(ctx, i.UserID)
+		}
+	}
+	return nil, nil
+}
+func (m *MockRepo) LinkIdentity(ctx context.Context, identity *model.Identity) error { return nil }
+func (m *MockRepo) InsertWithIdentity(ctx context.Context, user *model.User, identity *model.Identity) (int, error) {
+	if m.insertErr != nil {
+		return 0, m.insertErr
+	}
+	user.ID = len(m.users) + 1
+	m.users = append(m.users, *user)
+	i := *identity
+	i.UserID = user.ID
+	m.identities = append(m.identities, i)
+	return user.ID, nil
+}
+func (m *MockRepo) ListIdentities(ctx context.Context, userID int) ([]model.Identity, error) {
+	return nil, nil
+}
 
-func (m *MockRepo) FindByName(name string) ([]model.User, error) { return nil, nil }
-func (m *MockRepo) Insert(user *model.User) (int, error)         { return 1, nil }
+func newTestHasher() *auth.PasswordHasher { return auth.NewPasswordHasher(bcrypt.MinCost) }
 
 func TestUserUsecase_validateRegisterRequest(t *testing.T) {
-	u := NewUserUsecase(&MockRepo{})
+	u := NewUserUsecase(&MockRepo{}, newTestHasher(), nil)
 	tests := []struct {
 		name    string
 		req     RegisterUserReq
@@ -21,6 +95,9 @@ func TestUserUsecase_validateRegisterRequest(t *testing.T) {
 		{"名前空", RegisterUserReq{Name: "", Password: "password"}, true},
 		{"名前長すぎ", RegisterUserReq{Name: "123456789012345678901234567890123456789012345678901", Password: "password"}, true},
 		{"パスワード短すぎ", RegisterUserReq{Name: "Taro", Password: "01"}, true},
+		{"名前にコロン", RegisterUserReq{Name: "google:123", Password: "password"}, true},
+		{"メアド不正", RegisterUserReq{Name: "Taro", Password: "password", Email: "taro"}, true},
+		{"メアドあり", RegisterUserReq{Name: "Taro", Password: "password", Email: "taro@example.com"}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -30,3 +107,85 @@ func TestUserUsecase_validateRegisterRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestUserUsecase_Login(t *testing.T) {
+	hasher := newTestHasher()
+	hash, err := hasher.Hash("pass1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &MockRepo{users: []model.User{
+		{ID: 1, Name: "テスト太郎", Password: "pass1234"}, // 旧データ(平文)
+		{ID: 2, Name: "Taro", Password: hash},
+		{ID: 3, Name: "google@example.com", Password: ""}, // ソーシャルログイン専用
+	}}
+	u := NewUserUsecase(repo, hasher, nil)
+
+	tests := []struct {
+		name    string
+		req     LoginReq
+		wantErr bool
+	}{
+		{"平文の旧データ", LoginReq{Name: "テスト太郎", Password: "pass1234"}, false},
+		{"平文の旧データ・不一致", LoginReq{Name: "テスト太郎", Password: "wrong"}, true},
+		{"ハッシュ済み", LoginReq{Name: "Taro", Password: "pass1234"}, false},
+		{"ハッシュ済み・不一致", LoginReq{Name: "Taro", Password: "wrong"}, true},
+		{"パスワードなし", LoginReq{Name: "google@example.com", Password: ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := u.Login(context.Background(), tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 平文だったユーザーだけがハッシュし直されている
+	if len(repo.updated) != 1 || !auth.IsHashed(repo.updated[1]) {
+		t.Errorf("rehash = %v, want only user 1 rehashed", repo.updated)
+	}
+}
+
+type stubIDTokens struct {
+	claims *auth.IDTokenClaims
+}
+
+func (s stubIDTokens) Verify(ctx context.Context, idToken string) (*auth.IDTokenClaims, error) {
+	return s.claims, nil
+}
+
+func TestUserUsecase_SocialLoginCreatesUser(t *testing.T) {
+	claims := &auth.IDTokenClaims{Email: "hanako@example.com", EmailVerified: true, Name: "花子"}
+	claims.Subject = "sub-1"
+	repo := &MockRepo{}
+	u := NewUserUsecase(repo, newTestHasher(), stubIDTokens{claims})
+
+	id, name, err := u.SocialLogin(context.Background(), SocialLoginReq{IDToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "花子" || len(repo.users) != 1 || len(repo.identities) != 1 || repo.identities[0].UserID != id {
+		t.Fatalf("users = %+v, identities = %+v", repo.users, repo.identities)
+	}
+
+	// 2回目は紐付いたユーザーとしてログインする (もう1人は作らない)
+	again, _, err := u.SocialLogin(context.Background(), SocialLoginReq{IDToken: "token"})
+	if err != nil || again != id || len(repo.users) != 1 {
+		t.Errorf("second login = %d, %v (users %d)", again, err, len(repo.users))
+	}
+}
+
+// 紐付けに失敗したら、ユーザーも残らない (次のログインで重複して作らない)
+func TestUserUsecase_SocialLoginLinkFails(t *testing.T) {
+	claims := &auth.IDTokenClaims{Email: "hanako@example.com", EmailVerified: true}
+	claims.Subject = "sub-1"
+	repo := &MockRepo{insertErr: errors.New("duplicate entry")}
+	u := NewUserUsecase(repo, newTestHasher(), stubIDTokens{claims})
+
+	if _, _, err := u.SocialLogin(context.Background(), SocialLoginReq{IDToken: "token"}); err == nil {
+		t.Fatal("want error")
+	}
+	if len(repo.users) != 0 || len(repo.identities) != 0 {
+		t.Errorf("orphan rows: users = %+v, identities = %+v", repo.users, repo.identities)
+	}
+}